@@ -0,0 +1,180 @@
+package cmdiotest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/sh"
+)
+
+type cmd struct {
+	cmdio.Command
+
+	cdr  *Commander
+	ctx  context.Context
+	env  map[string]string
+	args []string
+	code int
+
+	start  func() error
+	finish func() error
+	call   *Call
+	resp   Response
+
+	mu     sync.Mutex
+	in     bytes.Buffer
+	closed chan struct{}
+	close  func()
+	out    *strings.Reader
+
+	attached bool
+	logger   io.Writer
+}
+
+func newCmd(
+	cdr *Commander, ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	c := &cmd{
+		cdr:    cdr,
+		ctx:    ctx,
+		env:    env,
+		args:   args,
+		closed: make(chan struct{}),
+	}
+	c.start = sync.OnceValue(c.startFunc)
+	c.finish = sync.OnceValue(c.finishFunc)
+	c.close = sync.OnceFunc(c.closeFunc)
+	return c
+}
+
+func (c *cmd) startFunc() error {
+	if len(c.args) == 0 {
+		return fmt.Errorf("no command")
+	}
+	c.call = &Call{
+		Args: slices.Clone(c.args),
+		Env:  maps.Clone(c.env),
+		Dir:  c.env["PWD"],
+	}
+	resp, ok := c.cdr.exec(c.call)
+	if !ok {
+		return &exec.Error{Name: c.args[0], Err: exec.ErrNotFound}
+	}
+	c.resp = resp
+	c.out = strings.NewReader(resp.Out)
+	if c.logger != nil && !c.attached {
+		_, _ = io.WriteString(c.logger, resp.Log)
+	}
+	return nil
+}
+
+func (c *cmd) Attach() error {
+	c.attached = true
+	return nil
+}
+
+func (c *cmd) Write(p []byte) (int, error) {
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.in.Write(p)
+}
+
+func (c *cmd) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	c.close()
+	return nil
+}
+
+func (c *cmd) closeFunc() {
+	c.mu.Lock()
+	in := c.in.String()
+	c.mu.Unlock()
+	c.cdr.setIn(c.call, in)
+	close(c.closed)
+}
+
+func (c *cmd) Read(p []byte) (int, error) {
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	if c.resp.In != nil {
+		select {
+		case <-c.closed:
+		case <-c.ctx.Done():
+			return 0, c.ctx.Err()
+		}
+	}
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if c.attached {
+		_, _ = io.Copy(os.Stdout, c.out)
+		_, _ = io.WriteString(os.Stderr, c.resp.Log)
+		if err := c.finish(); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	n, err := c.out.Read(p)
+	if err == io.EOF {
+		if err1 := c.finish(); err1 != nil {
+			err = err1
+		}
+	}
+	return n, err
+}
+
+func (c *cmd) finishFunc() error {
+	c.code = c.resp.Code
+	if c.resp.In != nil {
+		c.mu.Lock()
+		in := c.in.String()
+		c.mu.Unlock()
+		if err := c.resp.In(in); err != nil {
+			return err
+		}
+	}
+	if c.resp.Err != nil {
+		return c.resp.Err
+	}
+	if c.code != 0 {
+		return exitError(c.code)
+	}
+	return nil
+}
+
+func (c *cmd) Log(w io.Writer) {
+	c.logger = w
+}
+
+func (c *cmd) Code() int {
+	return c.code
+}
+
+func (c *cmd) Context() context.Context {
+	return c.ctx
+}
+
+func (c *cmd) String() string {
+	return cmdio.Redact(c.ctx, sh.String(c.ctx, c.env, c.args))
+}
+
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
@@ -0,0 +1,124 @@
+// Package cmdiotest provides a fake [cmdio.Commander] for testing code built
+// on [cmdio.Runner].
+//
+// Commands are matched against argument patterns registered with
+// [Commander.Handle] and respond with scripted output, diagnostics, and exit
+// codes. Every command that is executed is recorded as a [Call].
+package cmdiotest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/pattern"
+)
+
+// A Response describes the scripted behavior of a fake command.
+type Response struct {
+	Out  string // Written to standard output.
+	Log  string // Written to standard error.
+	Code int    // Exit code. A non-zero code fails the command.
+	Err  error  // Returned once output has been consumed, if non-nil.
+
+	// In, if non-nil, is called with the command's standard input once it has
+	// been closed. Output is withheld until then. A non-nil error fails the
+	// command.
+	In func(in string) error
+}
+
+// A Call records the execution of a command.
+type Call struct {
+	Args []string
+	Env  map[string]string
+	Dir  string // The PWD environment variable, if set.
+	In   string // Standard input written to the command.
+}
+
+type rule struct {
+	pattern []string
+	handler func(Call) Response
+}
+
+// A Commander is a fake [cmdio.Commander].
+//
+// The zero value is a Commander for which every command fails as though it
+// could not be found.
+type Commander struct {
+	mu    sync.Mutex
+	rules []rule
+	calls []*Call
+}
+
+// New instantiates a [cmdio.Runner] backed by a new [Commander].
+func New() (*cmdio.Runner, *Commander) {
+	cdr := new(Commander)
+	rnr := new(cmdio.Runner).
+		WithCommander(cdr).
+		WithContext(context.Background())
+	return rnr, cdr
+}
+
+// Handle registers a [Response] for commands matching pattern.
+//
+// Each element of pattern is matched against the corresponding argument using
+// [path.Match] syntax, except that "*" matches any argument, including those
+// containing slashes. If the final element of pattern is "...", it matches any
+// number of remaining arguments. When several patterns match a command,
+// the first one registered is used.
+func (c *Commander) Handle(pattern []string, r Response) {
+	c.HandleFunc(pattern, func(Call) Response { return r })
+}
+
+// HandleFunc registers a function that produces a [Response] for commands
+// matching pattern. Patterns are interpreted as described in
+// [Commander.Handle].
+//
+// The [Call] passed to handler does not include standard input.
+func (c *Commander) HandleFunc(pattern []string, handler func(Call) Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = append(c.rules, rule{slices.Clone(pattern), handler})
+}
+
+// Calls returns the commands executed so far, in order of execution.
+func (c *Commander) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]Call, len(c.calls))
+	for i, call := range c.calls {
+		calls[i] = *call
+	}
+	return calls
+}
+
+// Command instantiates a fake command.
+func (c *Commander) Command(
+	ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	return newCmd(c, ctx, env, args...)
+}
+
+func (c *Commander) exec(call *Call) (Response, bool) {
+	c.mu.Lock()
+	c.calls = append(c.calls, call)
+	rules := slices.Clone(c.rules)
+	c.mu.Unlock()
+	for _, r := range rules {
+		if pattern.Match(r.pattern, call.Args) {
+			call := *call
+			call.Args = slices.Clone(call.Args)
+			call.Env = maps.Clone(call.Env)
+			return r.handler(call), true
+		}
+	}
+	return Response{}, false
+}
+
+func (c *Commander) setIn(call *Call, in string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call.In = in
+}
@@ -0,0 +1,186 @@
+package cmdiotest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"lesiw.io/cmdio"
+)
+
+func TestGet(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New()
+	cdr.Handle([]string{"go", "version"}, Response{
+		Out: "go version go1.23.0 linux/amd64\n",
+		Log: "warning\n",
+	})
+
+	r, err := rnr.Get("go", "version")
+
+	if err != nil {
+		t.Errorf("rnr.Get().error = %q, want <nil>", err)
+	}
+	if got, want := r.Out, "go version go1.23.0 linux/amd64"; got != want {
+		t.Errorf("rnr.Get().Result.Out = %q, want %q", got, want)
+	}
+	if got, want := r.Log, "warning"; got != want {
+		t.Errorf("rnr.Get().Result.Log = %q, want %q", got, want)
+	}
+}
+
+func TestGetFailure(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New()
+	cdr.Handle([]string{"false"}, Response{Code: 42})
+
+	r, err := rnr.Get("false")
+
+	if err == nil {
+		t.Errorf("rnr.Get().error = <nil>, want error")
+	}
+	if got, want := r.Code, 42; got != want {
+		t.Errorf("rnr.Get().Result.Code = %d, want %d", got, want)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, _ := New()
+
+	_, err := rnr.Get("this-command-does-not-exist")
+
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("rnr.Get().error = %v, want exec.ErrNotFound", err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New()
+	cdr.Handle([]string{"git", "fetch"}, Response{Out: "fetch"})
+	cdr.Handle([]string{"git", "-C", "*", "..."}, Response{Out: "dir"})
+	cdr.Handle([]string{"git", "..."}, Response{Out: "git"})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"git", "fetch"}, "fetch"},
+		{[]string{"git", "fetch", "origin"}, "git"},
+		{[]string{"git", "-C", "/src", "status"}, "dir"},
+		{[]string{"git", "-C", "/src"}, "dir"},
+		{[]string{"git"}, "git"},
+	}
+	for _, tt := range tests {
+		r, err := rnr.Get(tt.args...)
+		if err != nil {
+			t.Errorf("rnr.Get(%q).error = %q, want <nil>", tt.args, err)
+		}
+		if got := r.Out; got != tt.want {
+			t.Errorf("rnr.Get(%q).Result.Out = %q, want %q",
+				tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestHandleFunc(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New()
+	cdr.HandleFunc([]string{"echo", "..."}, func(c Call) Response {
+		return Response{Out: strings.Join(c.Args[1:], " ")}
+	})
+
+	r, err := rnr.Get("echo", "hello", "world")
+
+	if err != nil {
+		t.Errorf("rnr.Get().error = %q, want <nil>", err)
+	}
+	if got, want := r.Out, "hello world"; got != want {
+		t.Errorf("rnr.Get().Result.Out = %q, want %q", got, want)
+	}
+}
+
+func TestIn(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New()
+	cdr.Handle([]string{"tr", "a-z", "A-Z"}, Response{
+		Out: "HELLO WORLD",
+		In: func(in string) error {
+			if in != "hello world" {
+				return fmt.Errorf("bad input: %q", in)
+			}
+			return nil
+		},
+	})
+
+	r, err := cmdio.GetPipe(
+		strings.NewReader("hello world"),
+		rnr.Command("tr", "a-z", "A-Z"),
+	)
+	if err != nil {
+		t.Errorf("GetPipe().error = %q, want <nil>", err)
+	}
+	if got, want := r.Out, "HELLO WORLD"; got != want {
+		t.Errorf("GetPipe().Result.Out = %q, want %q", got, want)
+	}
+
+	_, err = cmdio.GetPipe(
+		strings.NewReader("goodbye world"),
+		rnr.Command("tr", "a-z", "A-Z"),
+	)
+	if err == nil {
+		t.Errorf("GetPipe().error = <nil>, want error")
+	}
+}
+
+func TestCalls(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New()
+	cdr.Handle([]string{"..."}, Response{})
+	rnr = rnr.WithEnv(map[string]string{"PWD": "/src", "GOOS": "linux"})
+
+	_ = rnr.Command("go", "vet") // Never executed.
+	if _, err := rnr.Get("go", "build"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := cmdio.GetPipe(
+		strings.NewReader("input"),
+		rnr.Command("cat"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"PWD": "/src", "GOOS": "linux"}
+	want := []Call{
+		{Args: []string{"go", "build"}, Env: env, Dir: "/src"},
+		{Args: []string{"cat"}, Env: env, Dir: "/src", In: "input"},
+	}
+	if got := cdr.Calls(); !cmp.Equal(got, want) {
+		t.Errorf("cdr.Calls() -want +got\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestString(t *testing.T) {
+	rnr, _ := New()
+	rnr = rnr.WithEnv(map[string]string{"PWD": "/tmp"})
+
+	cmd := rnr.Command("echo", "hello world")
+
+	str := "PWD=/tmp echo 'hello world'"
+	if got, want := fmt.Sprint(cmd), str; got != want {
+		t.Errorf("Sprint(cmd) = %q, want %q", got, want)
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
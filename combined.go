@@ -0,0 +1,98 @@
+package cmdio
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Stream identifies the origin of a [Chunk] of output.
+type Stream int
+
+const (
+	Stdout Stream = iota + 1 // Standard output.
+	Stderr                   // Standard error, as captured by a [Logger].
+)
+
+func (s Stream) String() string {
+	switch s {
+	case Stdout:
+		return "out"
+	case Stderr:
+		return "log"
+	default:
+		return "?"
+	}
+}
+
+// A Chunk is a piece of output captured from a command.
+type Chunk struct {
+	Time   time.Time
+	Stream Stream
+	Data   []byte
+}
+
+type chunkLog struct {
+	mu     sync.Mutex
+	chunks []Chunk
+}
+
+func (l *chunkLog) writer(s Stream) io.Writer {
+	return chunkWriter{l, s}
+}
+
+func (l *chunkLog) get() []Chunk {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.chunks == nil {
+		return []Chunk{}
+	}
+	return l.chunks
+}
+
+type chunkWriter struct {
+	log    *chunkLog
+	stream Stream
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.log.mu.Lock()
+	defer w.log.mu.Unlock()
+	w.log.chunks = append(w.log.chunks, Chunk{
+		Time:   time.Now(),
+		Stream: w.stream,
+		Data:   bytes.Clone(p),
+	})
+	return len(p), nil
+}
+
+// fmtcombined formats chunks line by line, tagging each line with its stream.
+// Lines are ordered by when they were completed.
+func fmtcombined(chunks []Chunk) string {
+	var b strings.Builder
+	partial := make(map[Stream]string)
+	flush := func(s Stream, line string) {
+		b.WriteString("\n\t" + s.String() + ": " + line)
+	}
+	for _, c := range chunks {
+		lines := strings.Split(partial[c.Stream]+string(c.Data), "\n")
+		for _, line := range lines[:len(lines)-1] {
+			flush(c.Stream, line)
+		}
+		partial[c.Stream] = lines[len(lines)-1]
+	}
+	for _, s := range []Stream{Stdout, Stderr} {
+		if partial[s] != "" {
+			flush(s, partial[s])
+		}
+	}
+	if b.Len() == 0 {
+		return " <empty>\n"
+	}
+	return b.String() + "\n"
+}
@@ -0,0 +1,64 @@
+package cmdio_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+	"lesiw.io/cmdio/sys"
+)
+
+func TestGetCombined(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := sys.Runner()
+
+	r, err := rnr.GetCombined("sh", "-c",
+		"echo one; sleep 0.1; echo two >&2; sleep 0.1; echo three")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range r.Combined {
+		got = append(got, fmt.Sprintf("%s: %q", c.Stream, c.Data))
+	}
+	want := []string{`out: "one\n"`, `log: "two\n"`, `out: "three\n"`}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("rnr.GetCombined().Result.Combined = %q, want %q", got, want)
+	}
+	if got, want := r.Out, "one\nthree"; got != want {
+		t.Errorf("rnr.GetCombined().Result.Out = %q, want %q", got, want)
+	}
+	if got, want := r.Log, "two"; got != want {
+		t.Errorf("rnr.GetCombined().Result.Log = %q, want %q", got, want)
+	}
+	for i := 1; i < len(r.Combined); i++ {
+		if r.Combined[i].Time.Before(r.Combined[i-1].Time) {
+			t.Errorf("Combined[%d].Time is before Combined[%d].Time", i, i-1)
+		}
+	}
+}
+
+func TestGetCombinedError(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"build"}, cmdiotest.Response{
+		Out:  "compiled\nlinked",
+		Log:  "warning\nerror\n",
+		Code: 1,
+	})
+
+	_, err := rnr.GetCombined("build")
+
+	want := "exit status 1\noutput:\n" +
+		"\tlog: warning\n\tlog: error\n\tout: compiled\n\tout: linked\n" +
+		"code: 1"
+	if err == nil {
+		t.Fatalf("rnr.GetCombined().error = <nil>, want %q", want)
+	}
+	if got := err.Error(); got != want {
+		t.Errorf("rnr.GetCombined().error = %q, want %q", got, want)
+	}
+}
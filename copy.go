@@ -2,16 +2,30 @@ package cmdio
 
 import (
 	"io"
+	"sync/atomic"
 
 	"golang.org/x/sync/errgroup"
 )
 
 // Copy copies the output of each stream into the input of the next stream.
 // When output is finished copying from one stream, the receiving stream is
-// closed if it is an [io.Closer].
+// closed if it is an [io.Closer]. If copying from src fails and src is a
+// [Command], src is closed as well.
+//
+// For topologies other than a linear chain, see [Tee] and [Merge].
 func Copy(
 	dst io.Writer, src io.Reader, mid ...io.ReadWriter,
 ) (written int64, err error) {
+	written, _, err = copyStages(dst, src, mid, nil)
+	return
+}
+
+// copyStages is like [Copy], but it also reports the error encountered while
+// reading from each stage, where src is at index 0. If counts is non-nil, the
+// number of bytes read from each stage is added to it as copying progresses.
+func copyStages(
+	dst io.Writer, src io.Reader, mid []io.ReadWriter, counts []atomic.Int64,
+) (written int64, errs []error, err error) {
 	var (
 		g errgroup.Group
 		r io.Reader
@@ -20,6 +34,7 @@ func Copy(
 		count = make(chan int64)
 		total = make(chan int64)
 	)
+	errs = make([]error, len(mid)+1)
 	go func() {
 		var written int64
 		for n := range count {
@@ -41,6 +56,9 @@ func Copy(
 		i := i
 		w := w
 		r := r
+		if counts != nil {
+			r = countReader{r, &counts[i+1]}
+		}
 		g.Go(func() (err error) {
 			defer func() {
 				if c, ok := w.(io.Closer); ok {
@@ -51,6 +69,11 @@ func Copy(
 				}
 			}()
 			if n, err := io.Copy(w, r); err != nil {
+				if c, ok := src.(Command); ok && i < 0 {
+					// Let src clean up, as SIGPIPE would in a shell.
+					_ = c.Close()
+				}
+				errs[i+1] = err
 				return copyError{err, i + 1}
 			} else {
 				count <- n
@@ -60,7 +83,7 @@ func Copy(
 	}
 	err = g.Wait()
 	close(count)
-	return <-total, err
+	return <-total, errs, err
 }
 
 type copyError struct {
@@ -79,3 +102,14 @@ func (e copyError) Unwrap() error {
 func (e copyError) Offset() int {
 	return e.off
 }
+
+type countReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (r countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -2,6 +2,8 @@ package ctr
 
 import (
 	"context"
+	"maps"
+	"slices"
 
 	"golang.org/x/term"
 	"lesiw.io/cmdio"
@@ -36,6 +38,10 @@ func (c *cmd) Attach() error {
 	return nil
 }
 
+func (c *cmd) Context() context.Context {
+	return c.ctx
+}
+
 func (c *cmd) setCmd(attach bool) {
 	cmd := []string{"container", "exec"}
 	if attach {
@@ -49,14 +55,38 @@ func (c *cmd) setCmd(attach bool) {
 		// Unattached commands should not probe stdin/stdout.
 		cmd = append(cmd, "-i")
 	}
-	for k, v := range c.env {
-		if k == "PWD" {
-			cmd = append(cmd, "-w", c.env["PWD"])
-		} else {
-			cmd = append(cmd, "-e", k+"="+v)
+	set, unset, clean := cmdio.ParseEnv(c.ctx, c.env)
+	if dir, ok := set["PWD"]; ok {
+		cmd = append(cmd, "-w", dir)
+	}
+	if !clean {
+		for k, v := range set {
+			if k != "PWD" {
+				cmd = append(cmd, "-e", k+"="+v)
+			}
 		}
 	}
 	cmd = append(cmd, c.cdr.ctrid)
+	if clean || len(unset) > 0 {
+		// The container's own environment can only be pruned with env.
+		cmd = append(cmd, "env")
+		if clean {
+			cmd = append(cmd, "-i")
+		}
+		for _, k := range unset {
+			cmd = append(cmd, "-u", k)
+		}
+		if clean {
+			for _, k := range slices.Sorted(maps.Keys(set)) {
+				if k != "PWD" {
+					cmd = append(cmd, k+"="+set[k])
+				}
+			}
+		}
+	}
 	cmd = append(cmd, c.arg...)
-	c.Command = c.cdr.rnr.Commander.Command(c.ctx, nil, cmd...)
+	// Run the container CLI through its own Runner, so that the variables
+	// unset for the command are not unset for the CLI as well. Commands
+	// instantiated by a Runner are always Commands.
+	c.Command = c.cdr.rnr.WithContext(c.ctx).Command(cmd...).(cmdio.Command)
 }
@@ -0,0 +1,50 @@
+package ctr
+
+import (
+	"maps"
+	"strings"
+)
+
+func (c *cdr) Env(name string) string {
+	return c.env()[name]
+}
+
+func (c *cdr) Environ() map[string]string {
+	return maps.Clone(c.env())
+}
+
+// env returns the environment that commands inherit in the container, as
+// printed by an env command. The container's configuration is not used, since
+// it lacks variables that the runtime sets, such as HOME and HOSTNAME. It
+// returns nil, without caching the result, if the command fails.
+func (c *cdr) env() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.environ == nil {
+		c.environ = c.execEnv()
+	}
+	return c.environ
+}
+
+// execEnv returns the environment of an env command run in the container.
+// It returns nil if the command fails.
+func (c *cdr) execEnv() map[string]string {
+	r, err := c.rnr.Get("container", "exec", "-i", c.ctrid, "env")
+	if err != nil {
+		return nil
+	}
+	env := make(map[string]string)
+	var last string
+	for _, line := range strings.Split(r.Out, "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			if last != "" {
+				// Continuation of a multi-line value.
+				env[last] += "\n" + line
+			}
+			continue
+		}
+		env[k], last = v, k
+	}
+	return env
+}
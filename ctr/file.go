@@ -0,0 +1,60 @@
+package ctr
+
+import (
+	"context"
+	"path"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/archive"
+)
+
+func (c *cdr) CopyTo(
+	ctx context.Context, env map[string]string, src, dst string,
+) error {
+	rnr := c.rnr.WithContext(ctx)
+	dst, err := c.path(rnr, env, dst)
+	if err != nil {
+		return err
+	}
+	dir, name := archive.Split(dst)
+	r := archive.NewReader(src, name)
+	defer r.Close()
+	_, err = cmdio.GetPipe(r,
+		rnr.Command("container", "cp", "-", c.ctrid+":"+dir),
+	)
+	return err
+}
+
+func (c *cdr) CopyFrom(
+	ctx context.Context, env map[string]string, src, dst string,
+) error {
+	rnr := c.rnr.WithContext(ctx)
+	src, err := c.path(rnr, env, src)
+	if err != nil {
+		return err
+	}
+	_, err = cmdio.GetPipe(
+		rnr.Command("container", "cp", c.ctrid+":"+src, "-"),
+		archive.NewExtractor(dst),
+	)
+	return err
+}
+
+// path resolves name relative to the PWD in env or, if it is not set, the
+// working directory of the container.
+func (c *cdr) path(
+	rnr *cmdio.Runner, env map[string]string, name string,
+) (string, error) {
+	if path.IsAbs(name) {
+		return name, nil
+	}
+	dir, ok := env["PWD"]
+	if !ok {
+		r, err := rnr.Get("container", "exec", c.ctrid, "pwd")
+		if err != nil {
+			return "", err
+		}
+		dir = r.Out
+	}
+	return path.Join(dir, name), nil
+}
@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"lesiw.io/cmdio"
@@ -24,6 +25,9 @@ var clis = [...][]string{
 type cdr struct {
 	rnr   *cmdio.Runner
 	ctrid string
+
+	mu      sync.Mutex
+	environ map[string]string // Cached result of env, once it succeeds.
 }
 
 func (c *cdr) Command(
@@ -71,9 +75,10 @@ func WithRunner(
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
+	c := &cdr{rnr: rnr, ctrid: r.Out}
 	return new(cmdio.Runner).
 		WithContext(context.Background()).
-		WithCommander(&cdr{rnr: rnr, ctrid: r.Out}), nil
+		WithCommander(c), nil
 }
 
 func buildContainer(
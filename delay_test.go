@@ -0,0 +1,19 @@
+package cmdio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	r := Retry{Delay: time.Second}
+
+	var prev time.Duration
+	for n := 1; n <= 100; n++ {
+		d := r.delay(n)
+		if d < prev {
+			t.Fatalf("delay(%d) = %v, want at least %v", n, d, prev)
+		}
+		prev = d
+	}
+}
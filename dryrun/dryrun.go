@@ -0,0 +1,119 @@
+// Package dryrun provides a [cmdio.Commander] that previews commands instead
+// of executing them.
+//
+// Commands are traced as usual, rendered with their environment and working
+// directory exactly as the sys package renders them, and respond with canned
+// results. Commands without side effects may be allowed to run for real.
+package dryrun
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+	"lesiw.io/cmdio/internal/pattern"
+)
+
+type rule struct {
+	pattern []string
+	handler func(cmdiotest.Call) cmdiotest.Response
+}
+
+// A Commander is a [cmdio.Commander] that does not execute commands.
+//
+// Unless a response has been registered with [Commander.Handle], previewed
+// commands succeed without producing output.
+type Commander struct {
+	cdr  cmdio.Commander
+	fake *cmdiotest.Commander
+
+	mu    sync.Mutex
+	allow [][]string
+	rules []rule
+}
+
+// New instantiates a [cmdio.Runner] backed by a new [Commander].
+// The Runner has the context and environment of rnr, and commands allowed by
+// [Commander.Allow] are executed by the Commander of rnr.
+//
+// Commands that rnr routes to other Runners with [cmdio.Runner.WithCommand]
+// are previewed like any other, so the Runner does not keep these routes.
+func New(rnr *cmdio.Runner) (*cmdio.Runner, *Commander) {
+	cdr := &Commander{cdr: rnr.Commander, fake: new(cmdiotest.Commander)}
+	cdr.fake.HandleFunc([]string{"..."}, cdr.respond)
+	return rnr.WithoutCommands().WithCommander(cdr), cdr
+}
+
+// Allow permits commands matching pattern to be executed for real.
+// It should only be used for commands that are free of side effects, such as
+// those that inspect the state a script depends on.
+//
+// Patterns are interpreted as described in [cmdiotest.Commander.Handle].
+func (c *Commander) Allow(pattern []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.allow = append(c.allow, slices.Clone(pattern))
+}
+
+// Handle registers a canned [cmdiotest.Response] for previewed commands
+// matching pattern. When several patterns match a command, the first one
+// registered is used.
+//
+// Patterns are interpreted as described in [cmdiotest.Commander.Handle].
+func (c *Commander) Handle(pattern []string, r cmdiotest.Response) {
+	c.HandleFunc(pattern, func(cmdiotest.Call) cmdiotest.Response {
+		return r
+	})
+}
+
+// HandleFunc registers a function that produces a canned
+// [cmdiotest.Response] for previewed commands matching pattern.
+func (c *Commander) HandleFunc(
+	pattern []string, handler func(cmdiotest.Call) cmdiotest.Response,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = append(c.rules, rule{slices.Clone(pattern), handler})
+}
+
+// Calls returns the commands previewed so far, in order of execution.
+// Commands that were allowed to execute are not included.
+func (c *Commander) Calls() []cmdiotest.Call {
+	return c.fake.Calls()
+}
+
+// Command instantiates a command. Unless it is allowed, the command is only
+// previewed.
+func (c *Commander) Command(
+	ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	if c.allowed(args) {
+		return c.cdr.Command(ctx, env, args...)
+	}
+	return c.fake.Command(ctx, env, args...)
+}
+
+func (c *Commander) allowed(args []string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.allow {
+		if pattern.Match(p, args) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Commander) respond(call cmdiotest.Call) cmdiotest.Response {
+	c.mu.Lock()
+	rules := slices.Clone(c.rules)
+	c.mu.Unlock()
+	for _, r := range rules {
+		if pattern.Match(r.pattern, call.Args) {
+			return r.handler(call)
+		}
+	}
+	return cmdiotest.Response{}
+}
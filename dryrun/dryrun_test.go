@@ -0,0 +1,118 @@
+package dryrun
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+	"lesiw.io/cmdio/sys"
+)
+
+func TestTrace(t *testing.T) {
+	trace := new(bytes.Buffer)
+	swap[io.Writer](t, &cmdio.Trace, trace)
+	env := map[string]string{"PWD": "/src", "TAG": "v1.0.0"}
+	rnr, _ := New(sys.Runner().WithEnv(env))
+
+	args := []string{"git", "tag", "-m", "release notes", "v1.0.0"}
+	if err := rnr.Run(args...); err != nil {
+		t.Fatalf("rnr.Run() = %q, want <nil>", err)
+	}
+
+	cmd := sys.Runner().WithEnv(env).Command(args...)
+	if got, want := trace.String(), fmt.Sprintln(cmd); got != want {
+		t.Errorf("trace = %q, want %q", got, want)
+	}
+}
+
+func TestNoExecute(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	file := filepath.Join(t.TempDir(), "file")
+	rnr, cdr := New(sys.Runner())
+
+	if err := rnr.Run("touch", file); err != nil {
+		t.Fatalf("rnr.Run() = %q, want <nil>", err)
+	}
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(%q) = %v, want not exist", file, err)
+	}
+	got := cdr.Calls()
+	want := []cmdiotest.Call{{Args: []string{"touch", file}}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Calls() -want +got\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestNoExecuteRouted(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	file := filepath.Join(t.TempDir(), "file")
+	rnr, cdr := New(sys.Runner().WithCommand("touch", sys.Runner()))
+
+	if err := rnr.Run("touch", file); err != nil {
+		t.Fatalf("rnr.Run() = %q, want <nil>", err)
+	}
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(%q) = %v, want not exist", file, err)
+	}
+	if got, want := len(cdr.Calls()), 1; got != want {
+		t.Errorf("len(Calls()) = %d, want %d", got, want)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr, cdr := New(sys.Runner())
+	cdr.Handle([]string{"git", "describe", "..."}, cmdiotest.Response{
+		Out: "v0.9.0\n",
+	})
+	cdr.Handle([]string{"git", "push", "..."}, cmdiotest.Response{Code: 1})
+
+	r, err := rnr.Get("git", "describe", "--tags")
+	if err != nil {
+		t.Fatalf("rnr.Get() = %q, want <nil>", err)
+	}
+	if got, want := r.Out, "v0.9.0"; got != want {
+		t.Errorf("rnr.Get().Out = %q, want %q", got, want)
+	}
+	if err := rnr.Run("git", "push", "origin"); err == nil {
+		t.Errorf("rnr.Run() = <nil>, want error")
+	}
+}
+
+func TestAllow(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	dir := t.TempDir()
+	rnr, cdr := New(sys.Runner().WithEnv(map[string]string{"PWD": dir}))
+	cdr.Allow([]string{"pwd"})
+
+	r, err := rnr.Get("pwd")
+	if err != nil {
+		t.Fatalf("rnr.Get() = %q, want <nil>", err)
+	}
+
+	want, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := filepath.EvalSymlinks(r.Out); err != nil || got != want {
+		t.Errorf("rnr.Get(pwd).Out = %q, want %q", r.Out, want)
+	}
+	if got := cdr.Calls(); len(got) > 0 {
+		t.Errorf("Calls() = %v, want none", got)
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
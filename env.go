@@ -0,0 +1,103 @@
+package cmdio
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Unset is a sentinel environment variable value.
+//
+// Passing a variable with this value to [Runner.WithEnv] unsets it for
+// commands run by the new Runner, even if it would otherwise be inherited.
+const Unset = "\x00unset"
+
+type envKey struct{}
+
+// envOpts describes the variables that commands do not inherit from their
+// host. Runners keep them apart from env, so that [Commander] implementations
+// and tracers only ever see variables that are set.
+type envOpts struct {
+	unset []string // Sorted.
+	clean bool
+}
+
+// WithoutEnv creates a new Runner with the named environment variables unset.
+// The new Runner will share the same context and commander as its parent.
+func (rnr *Runner) WithoutEnv(names ...string) *Runner {
+	env := make(map[string]string, len(names))
+	for _, name := range names {
+		env[name] = Unset
+	}
+	return rnr.WithEnv(env)
+}
+
+// WithCleanEnv creates a new Runner whose commands do not inherit environment
+// variables from their host. Only variables set on the Runner with
+// [Runner.WithEnv] are passed to commands.
+// The new Runner will share the same context and commander as its parent.
+//
+// Note that commands run in a clean environment will not have a PATH unless
+// one is set explicitly.
+func (rnr *Runner) WithCleanEnv() *Runner {
+	rnr2 := rnr.clone()
+	rnr2.opts.clean = true
+	return rnr2
+}
+
+// ParseEnv interprets the context and env map passed to [Commander.Command].
+//
+// It returns the variables to set, the sorted names of the variables to unset,
+// and whether the command should be run in a clean environment rather than
+// inheriting one from its host.
+func ParseEnv(ctx context.Context, env map[string]string) (
+	set map[string]string, unset []string, clean bool,
+) {
+	set = make(map[string]string, len(env))
+	maps.Copy(set, env)
+	o, _ := ctx.Value(envKey{}).(envOpts)
+	return set, o.unset, o.clean
+}
+
+// Environ returns the environment of a command in "key=value" form, given the
+// environment inherited from its host and the context and env map passed to
+// [Commander.Command].
+func Environ(
+	ctx context.Context, base []string, env map[string]string,
+) []string {
+	set, unset, clean := ParseEnv(ctx, env)
+	ret := []string{}
+	if !clean {
+		for _, kv := range base {
+			k, _, _ := strings.Cut(kv, "=")
+			if _, ok := set[k]; ok || slices.Contains(unset, k) {
+				continue
+			}
+			ret = append(ret, kv)
+		}
+	}
+	keys := slices.Sorted(maps.Keys(set))
+	for _, k := range keys {
+		ret = append(ret, k+"="+set[k])
+	}
+	return ret
+}
+
+// addName returns a copy of the sorted names with name added.
+func addName(names []string, name string) []string {
+	i, ok := slices.BinarySearch(names, name)
+	if ok {
+		return names
+	}
+	return slices.Insert(slices.Clip(names), i, name)
+}
+
+// removeName returns a copy of the sorted names with name removed.
+func removeName(names []string, name string) []string {
+	i, ok := slices.BinarySearch(names, name)
+	if !ok {
+		return names
+	}
+	return slices.Delete(slices.Clone(names), i, i+1)
+}
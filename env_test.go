@@ -0,0 +1,82 @@
+package cmdio
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnviron(t *testing.T) {
+	base := []string{"HOME=/root", "GOFLAGS=-mod=mod", "PATH=/bin"}
+	cdr := &envCommander{base: base}
+	tests := []struct {
+		name string
+		rnr  *Runner
+		want []string
+	}{{
+		name: "inherit",
+		rnr:  new(Runner),
+		want: []string{"HOME=/root", "GOFLAGS=-mod=mod", "PATH=/bin"},
+	}, {
+		name: "override",
+		rnr:  new(Runner).WithEnv(map[string]string{"HOME": "/", "A": "1"}),
+		want: []string{"GOFLAGS=-mod=mod", "PATH=/bin", "A=1", "HOME=/"},
+	}, {
+		name: "unset",
+		rnr:  new(Runner).WithoutEnv("GOFLAGS", "MISSING"),
+		want: []string{"HOME=/root", "PATH=/bin"},
+	}, {
+		name: "reset",
+		rnr: new(Runner).WithoutEnv("GOFLAGS").
+			WithEnv(map[string]string{"GOFLAGS": "-v"}),
+		want: []string{"HOME=/root", "PATH=/bin", "GOFLAGS=-v"},
+	}, {
+		name: "clean",
+		rnr: new(Runner).WithEnv(map[string]string{"A": "1"}).
+			WithCleanEnv(),
+		want: []string{"A=1"},
+	}, {
+		name: "clean empty",
+		rnr:  new(Runner).WithCleanEnv(),
+		want: []string{},
+	}, {
+		name: "routed",
+		rnr: new(Runner).WithoutEnv("HOME").WithCommand("echo",
+			new(Runner).WithCommander(cdr).
+				WithEnv(map[string]string{"HOME": "/home"}).
+				WithoutEnv("PATH"),
+		),
+		want: []string{"GOFLAGS=-mod=mod"},
+	}}
+	for _, tt := range tests {
+		tt.rnr.WithCommander(cdr).Command("echo")
+		if !cmp.Equal(cdr.environ, tt.want) {
+			t.Errorf("%s: Environ() -want +got\n%s",
+				tt.name, cmp.Diff(tt.want, cdr.environ))
+		}
+		for k, v := range cdr.env {
+			if strings.Contains(k+v, "\x00") {
+				t.Errorf("%s: env[%q] = %q, want no sentinels",
+					tt.name, k, v)
+			}
+		}
+	}
+}
+
+// An envCommander records the environment of the last command instantiated.
+type envCommander struct {
+	base    []string
+	env     map[string]string
+	environ []string
+}
+
+func (c *envCommander) Command(
+	ctx context.Context, env map[string]string, args ...string,
+) Command {
+	c.env = maps.Clone(env)
+	c.environ = Environ(ctx, c.base, env)
+	return nil
+}
@@ -0,0 +1,64 @@
+package cmdio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An Error describes a failed command or pipeline.
+//
+// The error message masks any values marked as secret with
+// [Runner.WithSecret], but Result does not.
+//
+// [Runner.Run], [Runner.Get], [Pipe], and [GetPipe] return errors of type
+// *Error, regardless of the [Commander] that instantiated the commands.
+type Error struct {
+	// Cmd is the failing command, as formatted by its String method.
+	Cmd string
+	// Result is the result of the command or pipeline.
+	// Out and Log are only populated by functions that capture output.
+	Result Result
+	// Offset is the index of the failing stage in a pipeline, where the
+	// source is at index 0. It is 0 for single commands.
+	Offset int
+	// Err is the underlying error.
+	Err error
+
+	pipe    string   // Pipeline diagnostic.
+	get     bool     // Whether Result.Out and Result.Log were captured.
+	secrets []string // Values to mask.
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Err.Error())
+	if e.pipe != "" {
+		b.WriteString("\n\n" + e.pipe)
+	}
+	if e.get {
+		if e.pipe != "" {
+			b.WriteString("\n\n")
+		} else {
+			b.WriteString("\n")
+		}
+		if e.Result.Combined != nil {
+			fmt.Fprintf(&b, "output:%scode: %d",
+				fmtcombined(e.Result.Combined), e.Result.Code)
+		} else {
+			fmt.Fprintf(&b, "out:%slog:%scode: %d",
+				fmtout(e.Result.Out), fmtout(e.Result.Log), e.Result.Code)
+		}
+	}
+	return redact(e.secrets, b.String())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func cmdString(a any) string {
+	if str, ok := a.(fmt.Stringer); ok {
+		return strings.TrimRight(str.String(), "\n")
+	}
+	return fmt.Sprintf("<%T>", a)
+}
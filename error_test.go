@@ -0,0 +1,97 @@
+package cmdio_test
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+)
+
+func TestErrorGet(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"build"}, cmdiotest.Response{
+		Out:  "partial",
+		Log:  "boom",
+		Code: 42,
+	})
+
+	_, err := rnr.Get("build")
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("rnr.Get().error = %T, want *cmdio.Error", err)
+	}
+	if got, want := e.Cmd, "build"; got != want {
+		t.Errorf("Error.Cmd = %q, want %q", got, want)
+	}
+	if got, want := e.Result.Out, "partial"; got != want {
+		t.Errorf("Error.Result.Out = %q, want %q", got, want)
+	}
+	if got, want := e.Result.Log, "boom"; got != want {
+		t.Errorf("Error.Result.Log = %q, want %q", got, want)
+	}
+	if got, want := e.Result.Code, 42; got != want {
+		t.Errorf("Error.Result.Code = %d, want %d", got, want)
+	}
+	want := "exit status 42\nout:\n\tpartial\nlog:\n\tboom\ncode: 42"
+	if got := err.Error(); got != want {
+		t.Errorf("rnr.Get().error = %q, want %q", got, want)
+	}
+}
+
+func TestErrorRun(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, _ := cmdiotest.New()
+
+	err := rnr.Run("this-command-does-not-exist")
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("rnr.Run().error = %T, want *cmdio.Error", err)
+	}
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("rnr.Run().error = %q, want exec.ErrNotFound", err)
+	}
+	if got, want := e.Cmd, "this-command-does-not-exist"; got != want {
+		t.Errorf("Error.Cmd = %q, want %q", got, want)
+	}
+}
+
+func TestErrorPipe(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"grep", "..."}, cmdiotest.Response{Code: 1})
+	cdr.Handle([]string{"..."}, cmdiotest.Response{})
+
+	_, err := cmdio.GetPipe(
+		strings.NewReader("hello world"),
+		rnr.Command("grep", "goodbye"),
+		rnr.Command("wc", "-l"),
+	)
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("GetPipe().error = %T, want *cmdio.Error", err)
+	}
+	if got, want := e.Cmd, "grep goodbye"; got != want {
+		t.Errorf("Error.Cmd = %q, want %q", got, want)
+	}
+	if got, want := e.Offset, 1; got != want {
+		t.Errorf("Error.Offset = %d, want %d", got, want)
+	}
+	if got, want := e.Err.Error(), "exit status 1"; got != want {
+		t.Errorf("Error.Err = %q, want %q", got, want)
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
@@ -2,14 +2,17 @@ package cmdio
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"strings"
 
 	"golang.org/x/sync/errgroup"
 )
 
-func run(cmd io.Reader) error {
+// run runs cmd, tracing it with s. If s is nil, cmd is traced on its own.
+func run(cmd io.Reader, s *span) error {
+	if s == nil {
+		s = newSpan(cmd, nil, nil)
+	}
 	a, ok := cmd.(Attacher)
 	if !ok {
 		// If this command does not implement Attacher, stream it to stdout
@@ -17,34 +20,61 @@ func run(cmd io.Reader) error {
 		if l, ok := cmd.(Logger); ok {
 			l.Log(stderr)
 		}
-		fmt.Fprintln(Trace, strings.TrimRight(fmt.Sprintf("%v", cmd), "\n"))
-		_, err := io.Copy(stdout, cmd)
+		s.start()
+		n, err := io.Copy(stdout, cmd)
+		s.end(cmd, err, 0, n)
 		return err
 	}
 	if err := a.Attach(); err != nil {
 		return err
 	}
-	fmt.Fprintln(Trace, strings.TrimRight(fmt.Sprintf("%v", cmd), "\n"))
+	s.start()
 	_, err := cmd.Read(nil)
 	if err == io.EOF {
 		err = nil
 	}
+	s.end(cmd, err, 0, 0)
 	return err
 }
 
-func get(cmd io.Reader) (Result, error) {
-	fmt.Fprintln(Trace, strings.TrimRight(fmt.Sprintf("%v", cmd), "\n"))
+func get(cmd io.Reader, s *span) (Result, error) {
+	return capture(cmd, s, nil, nil, nil)
+}
+
+// capture is like get, but it also records output chunks to chunks and copies
+// output and diagnostic output to out and log, when they are non-nil.
+func capture(
+	cmd io.Reader, s *span, chunks *chunkLog, outw, logw io.Writer,
+) (Result, error) {
+	if s == nil {
+		s = newSpan(cmd, nil, nil)
+	}
+	s.start()
 
 	var r Result
 	var wg errgroup.Group
 	var log bytes.Buffer
+	var src io.Reader = cmd
+	var logws = []io.Writer{&log}
 	out := make(chan string)
 
+	if chunks != nil {
+		src = io.TeeReader(src, chunks.writer(Stdout))
+		logws = append(logws, chunks.writer(Stderr))
+	}
+	if outw != nil {
+		src = io.TeeReader(src, outw)
+	}
+	if logw != nil {
+		logws = append(logws, logw)
+	}
 	if l, ok := cmd.(Logger); ok {
-		l.Log(&log)
+		l.Log(io.MultiWriter(logws...))
 	}
+	var n int64
 	wg.Go(func() error {
-		buf, err := io.ReadAll(cmd)
+		buf, err := io.ReadAll(src)
+		n = int64(len(buf))
 		out <- strings.TrimRight(string(buf), "\n")
 		return err
 	})
@@ -56,6 +86,10 @@ func get(cmd io.Reader) (Result, error) {
 	if c, ok := cmd.(Coder); ok {
 		r.Code = c.Code()
 	}
+	if chunks != nil {
+		r.Combined = chunks.get()
+	}
+	s.end(cmd, err, 0, n)
 
 	return r, err
 }
@@ -0,0 +1,275 @@
+package cmdio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// An Expecter scripts interaction with a command that prompts for input.
+//
+// Steps are chained: each waits for output from the command or sends input to
+// it in turn. Once a step fails, subsequent steps have no effect, and
+// [Expecter.Wait] reports the failure.
+type Expecter struct {
+	ctx   context.Context
+	cmd   io.ReadWriter
+	out   *expectBuf
+	match []string
+	err   error
+	sent  int64
+	span  *span
+
+	cancel context.CancelFunc // Cancels the command, if it is owned.
+
+	secrets []string
+}
+
+// Expect executes a command for scripted interaction with an [Expecter].
+//
+// Each step is bounded by the Runner's context. If a step fails, the command is
+// canceled by [Expecter.Wait].
+func (rnr *Runner) Expect(args ...string) *Expecter {
+	ctx := rnr.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmdctx, cancel := context.WithCancel(ctx)
+	cmd := rnr.WithContext(cmdctx).Command(args...)
+	s := newSpan(cmd, rnr, args).start()
+	e := NewExpecter(ctx, cmd)
+	e.span = s
+	e.secrets = rnr.opts.secrets
+	e.cancel = cancel
+	return e
+}
+
+// NewExpecter returns an [Expecter] that interacts with cmd.
+// Each step is bounded by ctx.
+//
+// Output is read from cmd as soon as NewExpecter is called. If cmd implements
+// [Logger], its diagnostic output is matched along with its standard output.
+// [Expecter.Wait] must be called to release the command.
+func NewExpecter(ctx context.Context, cmd io.ReadWriter) *Expecter {
+	e := &Expecter{ctx: ctx, cmd: cmd, out: newExpectBuf()}
+	if l, ok := cmd.(Logger); ok {
+		l.Log(e.out)
+	}
+	go func() {
+		_, err := io.Copy(e.out, cmd)
+		e.out.close(err)
+	}()
+	return e
+}
+
+// Expect waits for the command to output s.
+// Output up to and including s is consumed.
+func (e *Expecter) Expect(s string) *Expecter {
+	re := regexp.MustCompile(regexp.QuoteMeta(s))
+	return e.expect(fmt.Sprintf("%q", s), re)
+}
+
+// ExpectRegexp waits for the command to output text matching re.
+// Output up to and including the match is consumed.
+//
+// Matching is attempted as output arrives, so patterns that match a variable
+// amount of text, such as `.*`, may match before the command has finished
+// writing.
+func (e *Expecter) ExpectRegexp(re *regexp.Regexp) *Expecter {
+	return e.expect("/"+re.String()+"/", re)
+}
+
+func (e *Expecter) expect(desc string, re *regexp.Regexp) *Expecter {
+	if e.err != nil {
+		return e
+	}
+	for {
+		ok, err := e.out.consume(func(b []byte) int {
+			loc := re.FindSubmatchIndex(b)
+			if loc == nil {
+				return -1
+			}
+			e.match = make([]string, len(loc)/2)
+			for i := range e.match {
+				if loc[2*i] >= 0 {
+					e.match[i] = string(b[loc[2*i]:loc[2*i+1]])
+				}
+			}
+			return loc[1]
+		})
+		if ok {
+			return e
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			e.err = fmt.Errorf("expect %s: %w", desc, err)
+			return e
+		}
+		select {
+		case <-e.out.notify:
+		case <-e.ctx.Done():
+			e.err = fmt.Errorf("expect %s: %w", desc, e.ctx.Err())
+			return e
+		}
+	}
+}
+
+// Send writes s to the command.
+func (e *Expecter) Send(s string) *Expecter {
+	if e.err != nil {
+		return e
+	}
+	n, err := io.WriteString(e.cmd, s)
+	e.sent += int64(n)
+	if err != nil {
+		e.err = fmt.Errorf("send %q: %w", s, err)
+	}
+	return e
+}
+
+// Match returns the text matched by the most recent successful expectation,
+// followed by the text of any parenthesized subexpressions.
+func (e *Expecter) Match() []string {
+	return e.match
+}
+
+// Wait closes the command's input if it is an [io.Closer] and waits for the
+// command to exit, even if a step failed.
+//
+// If any step failed or the command fails, the returned error is an [*Error].
+// Its Result holds the output that was not consumed by a step.
+func (e *Expecter) Wait() error {
+	if c, ok := e.cmd.(io.Closer); ok {
+		if err := c.Close(); err != nil && e.err == nil {
+			e.err = err
+		}
+	}
+	if e.cancel != nil {
+		defer e.cancel()
+		if e.err != nil {
+			// The command may be waiting for input that will never come.
+			e.cancel()
+		}
+	}
+	err := e.drain()
+	if err == io.EOF {
+		err = nil
+	}
+	if e.err != nil {
+		err = e.err
+	}
+	// The command's code is only available once its output is exhausted.
+	var cmd any
+	if e.out.done() != nil {
+		cmd = e.cmd
+	}
+	if e.span != nil {
+		e.span.end(cmd, err, e.sent, e.out.total())
+	}
+	if err == nil {
+		return nil
+	}
+	r := Result{Cmd: readWriter(e.cmd), Out: e.out.String()}
+	if c, ok := cmd.(Coder); ok {
+		r.Code = c.Code()
+	}
+	return &Error{
+		Cmd:     cmdString(e.cmd),
+		Result:  r,
+		Err:     err,
+		get:     true,
+		secrets: e.secrets,
+	}
+}
+
+// drain waits for the command's output to end and returns the error that
+// ended it.
+func (e *Expecter) drain() error {
+	for {
+		if err := e.out.done(); err != nil {
+			return err
+		}
+		select {
+		case <-e.out.notify:
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		}
+	}
+}
+
+// expectBuf accumulates command output for an [Expecter].
+type expectBuf struct {
+	mu     sync.Mutex
+	buf    []byte
+	n      int64
+	err    error // Set once output is exhausted.
+	notify chan struct{}
+}
+
+func newExpectBuf() *expectBuf {
+	return &expectBuf{notify: make(chan struct{}, 1)}
+}
+
+func (b *expectBuf) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	b.buf = append(b.buf, p...)
+	b.n += int64(len(p))
+	b.mu.Unlock()
+	b.signal()
+	return len(p), nil
+}
+
+func (b *expectBuf) close(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	b.mu.Lock()
+	b.err = err
+	b.mu.Unlock()
+	b.signal()
+}
+
+func (b *expectBuf) signal() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// consume discards the first find(buf) bytes of output and reports whether
+// find succeeded. If find fails, it returns the error that ended the output,
+// if any.
+func (b *expectBuf) consume(find func([]byte) int) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := find(b.buf); n >= 0 {
+		b.buf = b.buf[n:]
+		return true, nil
+	}
+	return false, b.err
+}
+
+// done returns the error that ended the output, if any.
+func (b *expectBuf) done() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// total returns the number of bytes of output received.
+func (b *expectBuf) total() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.n
+}
+
+func (b *expectBuf) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimRight(string(b.buf), "\n")
+}
@@ -0,0 +1,142 @@
+package cmdio_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+func ExampleRunner_Expect() {
+	rnr := sys.Runner()
+
+	e := rnr.Expect("sh", "-c", `printf 'name? '; read n;`+
+		`printf 'color? '; read c; echo "$n likes $c"`)
+	e.Expect("name? ").Send("gopher\n")
+	e.Expect("color? ").Send("blue\n")
+	e.ExpectRegexp(regexp.MustCompile(`(\w+) likes (\w+)`))
+	if err := e.Wait(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(e.Match()[1:])
+	// Output:
+	// [gopher blue]
+}
+
+func TestExpectEOF(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+
+	err := rnr.Expect("echo", "hello").Expect("goodbye").Wait()
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Wait() = %v, want io.ErrUnexpectedEOF", err)
+	}
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Wait() = %T, want *cmdio.Error", err)
+	}
+	if got, want := e.Result.Out, "hello"; got != want {
+		t.Errorf("Wait().Result.Out = %q, want %q", got, want)
+	}
+}
+
+func TestExpectTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 100*time.Millisecond,
+	)
+	defer cancel()
+	rnr := sys.Runner().WithContext(ctx)
+	swap(t, &cmdio.Trace, io.Discard)
+
+	start := time.Now()
+	err := rnr.Expect("sh", "-c", "sleep 10").Expect("prompt").Wait()
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Expect() took %v, want timeout", d)
+	}
+}
+
+func TestExpectLog(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+
+	e := rnr.Expect("sh", "-c", `printf 'password: ' >&2; read p; echo "$p"`)
+	err := e.Expect("password: ").Send("hunter2\n").Expect("hunter2").Wait()
+
+	if err != nil {
+		t.Errorf("Wait() = %v, want <nil>", err)
+	}
+}
+
+func TestExpectFailure(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+
+	err := rnr.Expect("sh", "-c", "echo ok; exit 2").Expect("ok").Wait()
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Wait() = %v, want *cmdio.Error", err)
+	}
+	if got, want := e.Result.Code, 2; got != want {
+		t.Errorf("Wait().Result.Code = %d, want %d", got, want)
+	}
+}
+
+func TestExpectSendFailure(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+
+	start := time.Now()
+	e := rnr.Expect("sh", "-c", "exec 0<&-; echo ready; sleep 10")
+	err := e.Expect("ready").Send("hello\n").Wait()
+
+	if err == nil {
+		t.Errorf("Wait() = <nil>, want error")
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Wait() took %v, want command canceled", d)
+	}
+}
+
+func TestExpectWaitDrains(t *testing.T) {
+	r, w := io.Pipe()
+	var closed atomic.Bool
+	go func() {
+		_, _ = io.WriteString(w, "hello\n")
+		time.Sleep(100 * time.Millisecond)
+		closed.Store(true)
+		_ = w.Close()
+	}()
+	cmd := struct {
+		io.Reader
+		io.Writer
+	}{r, failWriter{}}
+
+	err := cmdio.NewExpecter(context.Background(), cmd).Send("hi\n").Wait()
+
+	if err == nil {
+		t.Errorf("Wait() = <nil>, want error")
+	}
+	if !closed.Load() {
+		t.Errorf("Wait() returned before the output ended")
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
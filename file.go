@@ -0,0 +1,62 @@
+package cmdio
+
+import (
+	"context"
+	"fmt"
+
+	"lesiw.io/cmdio/internal/archive"
+)
+
+// CopyTo copies the local file or directory tree at src to dst on the system
+// that the Runner runs commands on, preserving mode bits and modification
+// times. Ownership is not preserved.
+//
+// The parent directory of dst must exist. If dst is an existing directory, the
+// contents of src are copied into it, replacing any files with the same names.
+// Relative paths in dst are relative to the PWD of the Runner, if set.
+//
+// By default, the tree is written as a tar stream to a tar command.
+// [Commander] implementations may customize this behavior by implementing
+// [FileCopier], unless the Runner has rules, which only apply to commands.
+func (rnr *Runner) CopyTo(src, dst string) error {
+	if c, ok := rnr.Commander.(FileCopier); ok && len(rnr.opts.rules) == 0 {
+		return c.CopyTo(rnr.context(), rnr.env, src, dst)
+	}
+	dir, name := archive.Split(dst)
+	r := archive.NewReader(src, name)
+	defer r.Close()
+	_, err := GetPipe(r, rnr.Command("tar", "-x", "-p", "-f", "-", "-C", dir))
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+// CopyFrom copies the file or directory tree at src on the system that the
+// Runner runs commands on to the local path dst. It is the inverse of
+// [Runner.CopyTo].
+//
+// By default, the tree is read as a tar stream from a tar command.
+// [Commander] implementations may customize this behavior by implementing
+// [FileCopier], unless the Runner has rules, which only apply to commands.
+func (rnr *Runner) CopyFrom(src, dst string) error {
+	if c, ok := rnr.Commander.(FileCopier); ok && len(rnr.opts.rules) == 0 {
+		return c.CopyFrom(rnr.context(), rnr.env, src, dst)
+	}
+	dir, name := archive.Split(src)
+	_, err := GetPipe(
+		rnr.Command("tar", "-c", "-f", "-", "-C", dir, name),
+		archive.NewExtractor(dst),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+func (rnr *Runner) context() context.Context {
+	if rnr.ctx == nil {
+		return context.Background()
+	}
+	return rnr.ctx
+}
@@ -0,0 +1,209 @@
+package cmdio_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sub"
+	"lesiw.io/cmdio/sys"
+)
+
+func TestCopyFallback(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	// A sub runner does not implement cmdio.FileCopier.
+	testCopy(t, sub.WithRunner(sys.Runner()))
+}
+
+func TestCopyFromSymlinkEscape(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	outside := t.TempDir()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	hdrs := []*tar.Header{
+		{Name: "root/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{
+			Name:     "root/link",
+			Typeflag: tar.TypeSymlink,
+			Linkname: outside,
+		},
+		{Name: "root/link/evil", Typeflag: tar.TypeReg, Mode: 0o644, Size: 4},
+	}
+	for _, hdr := range hdrs {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tw.Write([]byte("evil")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "evil.tar")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The tar command is replaced with one that writes the archive.
+	rnr := sub.WithRunner(sys.Runner(), "sh", "-c", `cat "$0"`, archive)
+
+	err := rnr.CopyFrom("tree", filepath.Join(t.TempDir(), "tree"))
+
+	if err == nil {
+		t.Errorf("CopyFrom() = <nil>, want error")
+	}
+	_, err = os.Lstat(filepath.Join(outside, "evil"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("CopyFrom() wrote outside of dst: Lstat() = %v", err)
+	}
+}
+
+func TestCopyRules(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	src, dir := testTree(t), t.TempDir()
+	rnr := sys.Runner().
+		WithEnv(map[string]string{"PWD": dir}).
+		WithRule(cmdio.RequireDir("/work"))
+
+	err := rnr.CopyTo(src, "tree")
+
+	if perr := new(cmdio.PolicyError); !errors.As(err, &perr) {
+		t.Errorf("CopyTo() = %v, want PolicyError", err)
+	}
+	_, err = os.Lstat(filepath.Join(dir, "tree"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("CopyTo() copied despite rule: Lstat() = %v", err)
+	}
+	err = rnr.CopyFrom(src, filepath.Join(t.TempDir(), "tree"))
+	if perr := new(cmdio.PolicyError); !errors.As(err, &perr) {
+		t.Errorf("CopyFrom() = %v, want PolicyError", err)
+	}
+}
+
+func testCopy(t *testing.T, rnr *cmdio.Runner) {
+	t.Helper()
+	src := testTree(t)
+	r, err := rnr.Get("mktemp", "-d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := r.Out
+	t.Cleanup(func() { _, _ = rnr.Get("rm", "-rf", dir) })
+	rnr = rnr.WithEnv(map[string]string{"PWD": dir})
+
+	if err := rnr.CopyTo(src, "tree"); err != nil {
+		t.Fatalf("CopyTo() = %q, want <nil>", err)
+	}
+	r, err = rnr.Get("cat", "tree/dir/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Out, "bravo"; got != want {
+		t.Errorf("[cat tree/dir/b] = %q, want %q", got, want)
+	}
+	dst := filepath.Join(t.TempDir(), "tree")
+	if err := rnr.CopyFrom("tree", dst); err != nil {
+		t.Fatalf("CopyFrom() = %q, want <nil>", err)
+	}
+
+	checkTree(t, src, dst)
+}
+
+// testTree creates a directory tree with a variety of modes and times.
+func testTree(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "src")
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	files := []struct {
+		name string
+		mode fs.FileMode
+		data string
+	}{
+		{"", 0o755, ""},
+		{"dir/", 0o750, ""},
+		{"a", 0o644, "alpha"},
+		{"dir/b", 0o600, "bravo"},
+		{"dir/run", 0o755, "#!/bin/sh\n"},
+	}
+	for _, f := range files {
+		name := filepath.Join(root, f.name)
+		var err error
+		if f.name == "" || strings.HasSuffix(f.name, "/") {
+			err = os.Mkdir(name, 0o700)
+		} else {
+			err = os.WriteFile(name, []byte(f.data), 0o600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// Set modes and times once the contents of each directory are written.
+	for i := len(files) - 1; i >= 0; i-- {
+		name := filepath.Join(root, files[i].name)
+		if err := os.Chmod(name, files[i].mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func checkTree(t *testing.T, src, dst string) {
+	t.Helper()
+	walk := func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		want, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		got, err := os.Lstat(filepath.Join(dst, rel))
+		if err != nil {
+			t.Errorf("%s: %v", rel, err)
+			return nil
+		}
+		if got, want := got.Mode(), want.Mode(); got != want {
+			t.Errorf("%s: mode = %v, want %v", rel, got, want)
+		}
+		switch {
+		case want.Mode()&fs.ModeSymlink != 0:
+			gotl, _ := os.Readlink(filepath.Join(dst, rel))
+			wantl, _ := os.Readlink(p)
+			if gotl != wantl {
+				t.Errorf("%s: link = %q, want %q", rel, gotl, wantl)
+			}
+			return nil
+		case want.Mode().IsRegular():
+			gotb, _ := os.ReadFile(filepath.Join(dst, rel))
+			wantb, _ := os.ReadFile(p)
+			if !bytes.Equal(gotb, wantb) {
+				t.Errorf("%s: content = %q, want %q", rel, gotb, wantb)
+			}
+		}
+		if got, want := got.ModTime(), want.ModTime(); !got.Equal(want) {
+			t.Errorf("%s: mtime = %v, want %v", rel, got, want)
+		}
+		return nil
+	}
+	if err := filepath.WalkDir(src, walk); err != nil {
+		t.Fatal(err)
+	}
+}
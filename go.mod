@@ -1,12 +1,24 @@
 module lesiw.io/cmdio
 
-go 1.21.0
+go 1.23.0
 
 require (
+	github.com/creack/pty v1.1.24
 	github.com/google/go-cmp v0.6.0
+	go.opentelemetry.io/otel v1.34.0
+	go.opentelemetry.io/otel/sdk v1.34.0
+	go.opentelemetry.io/otel/trace v1.34.0
+	golang.org/x/crypto v0.31.0
 	golang.org/x/sync v0.10.0
 	golang.org/x/term v0.27.0
 	lesiw.io/prefix v0.1.0
 )
 
-require golang.org/x/sys v0.28.0 // indirect
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
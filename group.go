@@ -0,0 +1,158 @@
+package cmdio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"lesiw.io/prefix"
+)
+
+// A Group runs commands concurrently and collects their results.
+//
+// A zero Group is valid, runs any number of commands at once, and waits for
+// all of them to finish. A Group must not be copied or reused after its first
+// use.
+type Group struct {
+	// Limit is the maximum number of commands that may run at once.
+	// If zero or negative, there is no limit.
+	Limit int
+	// FailFast, if true, cancels the remaining commands once one fails.
+	FailFast bool
+
+	once    sync.Once
+	eg      errgroup.Group
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	results []Result
+	errs    []error
+	first   error
+}
+
+func (g *Group) init() {
+	g.once.Do(func() {
+		g.ctx, g.cancel = context.WithCancel(context.Background())
+		if g.Limit > 0 {
+			g.eg.SetLimit(g.Limit)
+		}
+	})
+}
+
+// Go runs a command with the given [Runner] in a new goroutine.
+//
+// As the command runs, its output and diagnostic output are copied to
+// standard output and standard error, with each line prefixed by label.
+// If the Group's Limit has been reached, Go blocks until a command finishes.
+func (g *Group) Go(label string, rnr *Runner, args ...string) {
+	g.init()
+	g.mu.Lock()
+	i := len(g.results)
+	g.results = append(g.results, Result{})
+	g.errs = append(g.errs, nil)
+	g.mu.Unlock()
+
+	g.eg.Go(func() error {
+		ctx := rnr.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(g.ctx, cancel)()
+
+		cmd := rnr.WithContext(ctx).Command(args...)
+		if err := g.ctx.Err(); err != nil {
+			// A command failed before this one started.
+			e := &Error{Cmd: cmdString(cmd), Err: err}
+			g.done(i, Result{Cmd: cmd}, e)
+			return nil
+		}
+		outw := newLineWriter(label, stdout)
+		logw := newLineWriter(label, stderr)
+		r, err := capture(cmd, newSpan(cmd, rnr, args), nil, outw, logw)
+		outw.flush()
+		logw.flush()
+		if err != nil {
+			err = rnr.error(cmd, r, err)
+		}
+
+		g.done(i, r, err)
+		return nil
+	})
+}
+
+// done records the outcome of the command at index i.
+func (g *Group) done(i int, r Result, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.results[i], g.errs[i] = r, err
+	if err != nil && g.first == nil {
+		g.first = err
+		if g.FailFast {
+			g.cancel()
+		}
+	}
+}
+
+// Wait waits for all commands to finish and returns their results in the
+// order they were added.
+//
+// If FailFast is set, the returned error is the first failure. Otherwise, it
+// joins the errors of every failed command. Errors from individual commands
+// are of type [*Error].
+func (g *Group) Wait() ([]Result, error) {
+	g.init()
+	_ = g.eg.Wait() // Errors are collected by Go.
+	g.cancel()
+	if g.FailFast {
+		return g.results, g.first
+	}
+	return g.results, errors.Join(g.errs...)
+}
+
+// A lineWriter copies the output of a command to a writer shared with other
+// commands, prefixing each line with a label. Lines are written whole, so that
+// the output of concurrent commands does not interleave within a line.
+//
+// Copying is best effort: writes never fail, so that the command is not
+// disrupted if the shared writer is.
+type lineWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func newLineWriter(label string, w io.Writer) *lineWriter {
+	return &lineWriter{w: prefix.NewWriter(label+" | ", lockedWriter{w})}
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	if i := bytes.LastIndexByte(l.buf, '\n'); i >= 0 {
+		_, _ = l.w.Write(l.buf[:i+1])
+		l.buf = append(l.buf[:0], l.buf[i+1:]...)
+	}
+	return len(p), nil
+}
+
+// flush writes any incomplete last line.
+func (l *lineWriter) flush() {
+	if len(l.buf) > 0 {
+		_, _ = l.w.Write(append(l.buf, '\n'))
+		l.buf = nil
+	}
+}
+
+// lockedWriter serializes writes to a writer shared between commands.
+type lockedWriter struct{ w io.Writer }
+
+var writeMu sync.Mutex
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,125 @@
+package cmdio_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+func ExampleGroup() {
+	rnr := sys.Runner()
+	g := cmdio.Group{Limit: 1}
+	for _, pkg := range []string{"foo", "bar"} {
+		g.Go(pkg, rnr, "echo", "building", pkg)
+	}
+	results, err := g.Wait()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		fmt.Println(r.Out)
+	}
+	// Output:
+	// building foo
+	// building bar
+}
+
+func TestGroupCollectAll(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+	var g cmdio.Group
+
+	g.Go("a", rnr, "sh", "-c", "exit 1")
+	g.Go("b", rnr, "echo", "ok")
+	g.Go("c", rnr, "sh", "-c", "exit 2")
+	results, err := g.Wait()
+
+	if got, want := len(results), 3; got != want {
+		t.Fatalf("len(results) = %d, want %d", got, want)
+	}
+	for i, want := range []int{1, 0, 2} {
+		if got := results[i].Code; got != want {
+			t.Errorf("results[%d].Code = %d, want %d", i, got, want)
+		}
+	}
+	if got, want := results[1].Out, "ok"; got != want {
+		t.Errorf("results[1].Out = %q, want %q", got, want)
+	}
+	var errs interface{ Unwrap() []error }
+	if !errors.As(err, &errs) {
+		t.Fatalf("g.Wait() err = %v, want joined errors", err)
+	}
+	if got, want := len(errs.Unwrap()), 2; got != want {
+		t.Errorf("len(g.Wait() errors) = %d, want %d", got, want)
+	}
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Errorf("g.Wait() err = %T, want *cmdio.Error", err)
+	}
+}
+
+func TestGroupLog(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+	var g cmdio.Group
+
+	g.Go("a", rnr, "sh", "-c",
+		"echo one >&2; sleep 0.2; echo two >&2; printf three >&2; echo done")
+	results, err := g.Wait()
+
+	if err != nil {
+		t.Fatalf("g.Wait() err = %v", err)
+	}
+	if got, want := results[0].Log, "one\ntwo\nthree"; got != want {
+		t.Errorf("results[0].Log = %q, want %q", got, want)
+	}
+	if got, want := results[0].Out, "done"; got != want {
+		t.Errorf("results[0].Out = %q, want %q", got, want)
+	}
+}
+
+func TestGroupFailFast(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+	g := cmdio.Group{FailFast: true}
+
+	start := time.Now()
+	g.Go("slow", rnr, "sleep", "10")
+	g.Go("fail", rnr, "sh", "-c", "sleep 0.1; exit 3")
+	_, err := g.Wait()
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("g.Wait() err = %v, want *cmdio.Error", err)
+	}
+	if got, want := e.Result.Code, 3; got != want {
+		t.Errorf("g.Wait() err code = %d, want %d", got, want)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("g.Wait() took %v, want cancellation", d)
+	}
+}
+
+func TestGroupLimit(t *testing.T) {
+	rnr := sys.Runner()
+	swap(t, &cmdio.Trace, io.Discard)
+	g := cmdio.Group{Limit: 2}
+
+	start := time.Now()
+	for range 4 {
+		g.Go("sleep", rnr, "sleep", "0.2")
+	}
+	if _, err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("g.Wait() took %v, want at least 400ms", d)
+	}
+}
@@ -0,0 +1,328 @@
+// Package archive copies file trees as tar streams.
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Split splits a slash-separated path into the directory to run tar in and
+// the name of the tree's root within it.
+func Split(p string) (dir, name string) {
+	p = path.Clean(p)
+	dir, name = path.Split(p)
+	if name == "" || name == "." || name == ".." {
+		return p, "."
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return dir, name
+}
+
+// Write writes the file or directory tree at src to w as a tar stream whose
+// root is named name.
+//
+// Ownership is not recorded, so extracted files belong to the extracting user.
+func Write(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if rel != "." {
+			hdr.Name += "/" + filepath.ToSlash(rel)
+		}
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	}
+	if err := filepath.WalkDir(src, walk); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// Extract extracts a tar stream into dst, replacing the first element of the
+// name of each entry with dst. Modes and modification times are preserved.
+func Extract(r io.Reader, dst string) error {
+	type dir struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dir
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		target, err := entryPath(dst, hdr.Name)
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err := noSymlink(target)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			// Keep directories writable until their contents are extracted.
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dir{target, hdr})
+			continue
+		case tar.TypeReg:
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := replace(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeLink:
+			old, err := entryPath(dst, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := replace(target); err != nil {
+				return err
+			}
+			if err := os.Link(old, target); err != nil {
+				return err
+			}
+			continue
+		default:
+			continue // Other file types are not copied.
+		}
+		if err := os.Chmod(target, mode&chmodBits); err != nil {
+			return err
+		}
+		if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d, mtime := dirs[i], dirs[i].hdr.ModTime
+		mode := d.hdr.FileInfo().Mode()
+		if err := os.Chmod(d.path, mode&chmodBits); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const chmodBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// entryPath returns where the entry with the given name is extracted to.
+//
+// Entries are never extracted through symbolic links, including those
+// extracted from earlier entries, so that they cannot escape dst.
+func entryPath(dst, name string) (string, error) {
+	_, rest, _ := strings.Cut(strings.TrimSuffix(name, "/"), "/")
+	if rest == "" {
+		return dst, nil
+	}
+	rest = filepath.FromSlash(path.Clean(rest))
+	if !filepath.IsLocal(rest) {
+		return "", fmt.Errorf("archive entry outside of root: %s", name)
+	}
+	p := dst
+	elems := strings.Split(rest, string(filepath.Separator))
+	for _, elem := range elems[:len(elems)-1] {
+		p = filepath.Join(p, elem)
+		if err := noSymlink(p); errors.Is(err, fs.ErrNotExist) {
+			break // Nor do any of its children.
+		} else if err != nil {
+			return "", fmt.Errorf("archive entry %s: %w", name, err)
+		}
+	}
+	return filepath.Join(dst, rest), nil
+}
+
+// noSymlink returns an error if the file at name is a symbolic link or cannot
+// be found.
+func noSymlink(name string) error {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("not extracting through symbolic link: %s", name)
+	}
+	return nil
+}
+
+func writeFile(name string, r io.Reader, mode fs.FileMode) error {
+	if err := replace(name); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replace removes any file at name, so that it can be replaced. Directories
+// are not removed.
+func replace(name string) error {
+	info, err := os.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot replace directory: %s", name)
+	}
+	return os.Remove(name)
+}
+
+// Copy copies the file or directory tree at src to dst, as if by writing it
+// as a tar stream and extracting it.
+func Copy(src, dst string) error {
+	r := NewReader(src, "root")
+	err := Extract(r, dst)
+	_, _ = io.Copy(io.Discard, r) // Unblock the writer.
+	return errors.Join(err, r.Close())
+}
+
+// A Reader reads the tar stream of a file tree as it is written.
+type Reader struct {
+	src   string
+	name  string
+	start func()
+	r     *io.PipeReader
+}
+
+// NewReader returns a Reader for the tree at src whose root is named name.
+func NewReader(src, name string) *Reader {
+	r := &Reader{src: src, name: name}
+	r.start = sync.OnceFunc(func() {
+		var w *io.PipeWriter
+		r.r, w = io.Pipe()
+		go func() { _ = w.CloseWithError(Write(w, src, name)) }()
+	})
+	return r
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	r.start()
+	return r.r.Read(p)
+}
+
+// Close stops writing the stream.
+func (r *Reader) Close() error {
+	r.start()
+	return r.r.Close()
+}
+
+func (r *Reader) String() string {
+	return "<archive " + r.src + ">"
+}
+
+// An Extractor extracts the tar stream written to it into a directory.
+//
+// Reading from an Extractor waits for extraction to finish, then returns
+// io.EOF or the error extraction failed with.
+type Extractor struct {
+	dst  string
+	w    *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// NewExtractor returns an Extractor that extracts into dst as [Extract] does.
+func NewExtractor(dst string) *Extractor {
+	pr, pw := io.Pipe()
+	e := &Extractor{dst: dst, w: pw, done: make(chan struct{})}
+	go func() {
+		defer close(e.done)
+		if e.err = Extract(pr, dst); e.err != nil {
+			_ = pr.CloseWithError(e.err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, pr) // Drain any padding.
+	}()
+	return e
+}
+
+func (e *Extractor) Write(p []byte) (int, error) {
+	return e.w.Write(p)
+}
+
+// Close signals the end of the stream and waits for extraction to finish.
+func (e *Extractor) Close() error {
+	_ = e.w.Close()
+	<-e.done
+	return e.err
+}
+
+func (e *Extractor) Read([]byte) (int, error) {
+	<-e.done
+	if e.err != nil {
+		return 0, e.err
+	}
+	return 0, io.EOF
+}
+
+func (e *Extractor) String() string {
+	return "<extract " + e.dst + ">"
+}
@@ -0,0 +1,28 @@
+// Package pattern matches command arguments against patterns.
+package pattern
+
+import "path"
+
+// Match reports whether args matches pattern.
+//
+// Each element of pattern is matched against the corresponding argument using
+// [path.Match] syntax, except that "*" matches any argument, including those
+// containing slashes. If the final element of pattern is "...", it matches any
+// number of remaining arguments.
+func Match(pattern, args []string) bool {
+	for i, p := range pattern {
+		if p == "..." && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(args) {
+			return false
+		}
+		if p == "*" {
+			continue
+		}
+		if ok, _ := path.Match(p, args[i]); !ok {
+			return false
+		}
+	}
+	return len(pattern) == len(args)
+}
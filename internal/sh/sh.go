@@ -0,0 +1,72 @@
+// Package sh renders commands as POSIX shell strings.
+package sh
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	"lesiw.io/cmdio"
+)
+
+var shUnsafe = regexp.MustCompile(`[^\w@%+=:,./-]`)
+
+// Quote quotes s for use as a single shell word.
+func Quote(s string) string {
+	if s == "" {
+		return `''`
+	}
+	if !shUnsafe.MatchString(s) {
+		return s
+	}
+	return `'` + strings.ReplaceAll(s, `'`, `'\''`) + `'`
+}
+
+// Join quotes and joins parts into a shell command line.
+func Join(parts []string) string {
+	quotedParts := make([]string, len(parts))
+	for i, part := range parts {
+		quotedParts[i] = Quote(part)
+	}
+	return strings.Join(quotedParts, " ")
+}
+
+// String renders a command as sys would trace it: env assignments in key
+// order, followed by the quoted arguments.
+//
+// Unset variables and clean environments, which are read from ctx, are
+// rendered as options to env.
+func String(
+	ctx context.Context, env map[string]string, args []string,
+) string {
+	ret := new(strings.Builder)
+	set, unset, clean := cmdio.ParseEnv(ctx, env)
+	if clean || len(unset) > 0 {
+		ret.WriteString("env ")
+		if clean {
+			ret.WriteString("-i ")
+		}
+		for _, k := range unset {
+			ret.WriteString("-u " + k + " ")
+		}
+	}
+	for _, k := range SortKeys(set) {
+		ret.WriteString(k + "=" + set[k] + " ")
+	}
+	ret.WriteString(Join(args))
+	return ret.String()
+}
+
+// SortKeys returns the keys of m in sorted order.
+func SortKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, len(m))
+	var i int
+	for k := range m {
+		keys[i] = k
+		i++
+	}
+	slices.Sort(keys)
+	return keys
+}
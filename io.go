@@ -7,7 +7,8 @@
 //
 // Commands are instantiated by a [Runner]. This package contains several
 // Runner implementations: [lesiw.io/cmdio/sys], which runs commands on the
-// local system; [lesiw.io/cmdio/ctr], which runs commands in containers; and
+// local system; [lesiw.io/cmdio/ctr], which runs commands in containers;
+// [lesiw.io/cmdio/ssh], which runs commands on remote hosts; and
 // [lesiw.io/cmdio/sub], which runs commands as subcommands.
 //
 // While most of this package is written to support traditional Go error
@@ -41,11 +42,32 @@ type Commander interface {
 // An Enver has environment variables.
 //
 // A [Commander] that also implements this interface will call Env to retrieve
-// environment variables.
+// environment variables. Env reports the environment that commands inherit
+// from their host. Variables set or unset on a [Runner] take precedence.
 type Enver interface {
 	Env(name string) (value string)
 }
 
+// An Environer has environment variables.
+//
+// A [Commander] that also implements this interface will call Environ to
+// retrieve every environment variable at once. Like [Enver], it reports the
+// environment that commands inherit from their host.
+type Environer interface {
+	Environ() map[string]string
+}
+
+// A FileCopier copies files to and from the system that a [Commander] runs
+// commands on.
+//
+// A [Commander] that also implements this interface will call CopyTo and
+// CopyFrom to implement [Runner.CopyTo] and [Runner.CopyFrom]. Relative paths
+// on the Commander's system are relative to the PWD in env, if set.
+type FileCopier interface {
+	CopyTo(ctx context.Context, env map[string]string, src, dst string) error
+	CopyFrom(ctx context.Context, env map[string]string, src, dst string) error
+}
+
 // A Logger accepts an [io.Writer] for logging diagnostic information.
 //
 // Implementing this interface is the idiomatic way for commands to represent
@@ -70,6 +92,21 @@ type Attacher interface {
 	Attach() error
 }
 
+// A Contexter has the context it was instantiated with.
+//
+// Commands should implement this interface by returning the context passed to
+// [Commander.Command], so that the commands of a pipeline can be traced within
+// the context of the Runners that instantiated them, and so that pipeline
+// errors mask the secrets of those Runners.
+type Contexter interface {
+	Context() context.Context
+}
+
+// A Resizer has a terminal whose window size can be changed.
+type Resizer interface {
+	Resize(rows, cols int) error
+}
+
 // A [Command] is the broadest possible command interface.
 //
 // Commands must not begin execution until the first time they are read from or
@@ -89,6 +126,9 @@ type Command interface {
 
 // Trace is an [io.Writer] to which command tracing information is written.
 // To disable tracing, set this variable to [io.Discard].
+//
+// Trace is written to by the default [TraceHook]. For structured tracing, see
+// [Tracer].
 var Trace io.Writer = prefix.NewWriter("+ ", stderr)
 
 var (
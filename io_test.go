@@ -17,7 +17,7 @@ func TestRun(t *testing.T) {
 	swap[io.Writer](t, &Trace, errbuf)
 	cmd := bytes.NewBufferString("hello world")
 
-	err := run(cmd)
+	err := run(cmd, nil)
 
 	if err != nil {
 		t.Errorf("Run(%q) = %q, want <nil>", cmd, err)
@@ -41,7 +41,7 @@ func TestRunError(t *testing.T) {
 	swap[io.Writer](t, &stderr, errbuf)
 	cmd := iotest.ErrReader(errors.New("some error"))
 
-	err := run(cmd)
+	err := run(cmd, nil)
 
 	if got, want := err.Error(), "some error"; got != want {
 		t.Errorf("Run() = %q, want %q", got, want)
@@ -78,7 +78,7 @@ func TestRunAttacher(t *testing.T) {
 	swap[io.Writer](t, &stderr, errbuf)
 	a := new(testAttacher)
 
-	err := run(a)
+	err := run(a, nil)
 
 	if err != nil {
 		t.Errorf("Run() = %q, want <nil>", err)
@@ -108,7 +108,7 @@ func TestRunAttacherAttachError(t *testing.T) {
 	a := new(testAttacher)
 	a.attachError = errors.New("attach error")
 
-	err := run(a)
+	err := run(a, nil)
 
 	if got, want := err.Error(), "attach error"; got != want {
 		t.Errorf("Run() = %q, want %q", got, want)
@@ -135,7 +135,7 @@ func TestRunAttacherReadError(t *testing.T) {
 	a := new(testAttacher)
 	a.readError = errors.New("read error")
 
-	err := run(a)
+	err := run(a, nil)
 
 	if got, want := err.Error(), "read error"; got != want {
 		t.Errorf("Run() = %q, want %q", got, want)
@@ -163,7 +163,7 @@ func TestGet(t *testing.T) {
 	swap[io.Writer](t, &Trace, errbuf)
 	cmd := bytes.NewBufferString("hello world")
 
-	r, err := get(cmd)
+	r, err := get(cmd, nil)
 
 	checkEqual(t, fmt.Sprintf("Get(%q).CmdResult", cmd), r, Result{
 		Cmd: cmd, Out: "hello world",
@@ -190,7 +190,7 @@ func TestGetError(t *testing.T) {
 	swap[io.Writer](t, &stderr, errbuf)
 	cmd := iotest.ErrReader(errors.New("some error"))
 
-	_, err := get(cmd)
+	_, err := get(cmd, nil)
 
 	if got, want := err.Error(), "some error"; got != want {
 		t.Errorf("Get().error = %q, want %q", got, want)
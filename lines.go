@@ -0,0 +1,92 @@
+package cmdio
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"iter"
+	"strings"
+	"sync/atomic"
+)
+
+// Lines executes a command and returns an iterator over the lines of its
+// output, with trailing end-of-line markers removed.
+//
+// Output is read as the command produces it. If the command fails, the last
+// iteration yields an [*Error]. If iteration stops early, the command is
+// canceled.
+func (rnr *Runner) Lines(args ...string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for tok, err := range rnr.Scan(bufio.ScanLines, args...) {
+			if !yield(string(tok), err) {
+				return
+			}
+		}
+	}
+}
+
+// Scan executes a command and returns an iterator over the tokens of its
+// output, as split by the given [bufio.SplitFunc].
+//
+// The underlying array of a token may be overwritten by subsequent
+// iterations. Failures and early termination are handled as in
+// [Runner.Lines].
+func (rnr *Runner) Scan(
+	split bufio.SplitFunc, args ...string,
+) iter.Seq2[[]byte, error] {
+	return rnr.scan(true, split, args...)
+}
+
+func (rnr *Runner) scan(
+	trace bool, split bufio.SplitFunc, args ...string,
+) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		ctx := rnr.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cmd := rnr.WithContext(ctx).Command(args...)
+		var n atomic.Int64
+		done := func(error) {}
+		if trace {
+			s := newSpan(cmd, rnr, args).start()
+			done = func(err error) { s.end(cmd, err, 0, n.Load()) }
+		}
+		var log syncBuffer
+		if l, ok := cmd.(Logger); ok {
+			l.Log(&log)
+		}
+
+		scanner := bufio.NewScanner(countReader{cmd, &n})
+		scanner.Split(split)
+		for scanner.Scan() {
+			if !yield(scanner.Bytes(), nil) {
+				cancel()
+				_, _ = io.Copy(io.Discard, cmd) // Reap the command.
+				done(nil)
+				return
+			}
+		}
+		err := scanner.Err()
+		if err != nil {
+			// The scanner may have stopped before the command's output did.
+			cancel()
+			_, _ = io.Copy(io.Discard, cmd) // Reap the command.
+		}
+		done(err)
+		if err != nil {
+			r := Result{Cmd: cmd, Log: strings.TrimRight(log.String(), "\n")}
+			if c, ok := cmd.(Coder); ok {
+				r.Code = c.Code()
+			}
+			yield(nil, &Error{
+				Cmd:     cmdString(cmd),
+				Result:  r,
+				Err:     err,
+				secrets: rnr.opts.secrets,
+			})
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cmdio_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"testing"
+	"time"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+	"lesiw.io/cmdio/sys"
+)
+
+func ExampleRunner_Lines() {
+	rnr := sys.Runner()
+	for line, err := range rnr.Lines("printf", "hello\nworld\n") {
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("line:", line)
+	}
+	// Output:
+	// line: hello
+	// line: world
+}
+
+func ExampleRunner_Scan() {
+	rnr := sys.Runner()
+	for word, err := range rnr.Scan(bufio.ScanWords, "echo", "a b  c") {
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%q\n", word)
+	}
+	// Output:
+	// "a"
+	// "b"
+	// "c"
+}
+
+func TestLinesError(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"build"}, cmdiotest.Response{
+		Out:  "one\ntwo\n",
+		Log:  "failed",
+		Code: 2,
+	})
+
+	var lines []string
+	var err error
+	for line, lerr := range rnr.Lines("build") {
+		if lerr != nil {
+			err = lerr
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	if got, want := fmt.Sprint(lines), "[one two]"; got != want {
+		t.Errorf("rnr.Lines(build) = %s, want %s", got, want)
+	}
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("rnr.Lines(build) error = %v, want *cmdio.Error", err)
+	}
+	if got, want := e.Result.Code, 2; got != want {
+		t.Errorf("Error.Result.Code = %d, want %d", got, want)
+	}
+	if got, want := e.Result.Log, "failed"; got != want {
+		t.Errorf("Error.Result.Log = %q, want %q", got, want)
+	}
+}
+
+func TestLinesStop(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := sys.Runner()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var n int
+		for _, err := range rnr.Lines("yes") {
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if n++; n == 3 {
+				break
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rnr.Lines(yes) did not stop")
+	}
+}
+
+func TestScanSplitError(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := sys.Runner()
+	errSplit := errors.New("bad token")
+	split := func([]byte, bool) (int, []byte, error) {
+		return 0, nil, errSplit
+	}
+
+	before := runtime.NumGoroutine()
+	for range 20 {
+		var err error
+		for _, serr := range rnr.Scan(split, "yes") {
+			err = serr
+		}
+		if !errors.Is(err, errSplit) {
+			t.Fatalf("rnr.Scan(yes) error = %v, want %v", err, errSplit)
+		}
+	}
+
+	// Give exiting goroutines a moment to finish.
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before+5 {
+		t.Errorf("goroutines = %d after 20 scans, want about %d",
+			after, before)
+	}
+}
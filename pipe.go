@@ -2,14 +2,16 @@ package cmdio
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-func pipeTrace(src io.Reader, mid []io.ReadWriter) {
+func pipeString(src io.Reader, mid []io.ReadWriter) string {
+	var b strings.Builder
 	var e any
 	for i := -1; i < len(mid); i++ {
 		if i < 0 {
@@ -18,15 +20,11 @@ func pipeTrace(src io.Reader, mid []io.ReadWriter) {
 			e = mid[i]
 		}
 		if i > -1 {
-			fmt.Fprintf(Trace, " | ")
-		}
-		if str, ok := e.(fmt.Stringer); ok {
-			fmt.Fprint(Trace, strings.TrimRight(str.String(), "\n"))
-		} else {
-			fmt.Fprintf(Trace, "<%T>", e)
+			b.WriteString(" | ")
 		}
+		b.WriteString(cmdString(e))
 	}
-	fmt.Fprintln(Trace)
+	return b.String()
 }
 
 func pipeErr(src io.Reader, cmd []io.ReadWriter, err error) string {
@@ -42,11 +40,7 @@ func pipeErr(src io.Reader, cmd []io.ReadWriter, err error) string {
 			if i > -1 {
 				b.WriteString("\n")
 			}
-			if str, ok := e.(fmt.Stringer); ok {
-				b.WriteString(strings.TrimRight(str.String(), "\n"))
-			} else {
-				fmt.Fprintf(&b, "<%T>", e)
-			}
+			b.WriteString(cmdString(e))
 			if i < len(cmd)-1 {
 				b.WriteString(" |")
 			}
@@ -58,25 +52,153 @@ func pipeErr(src io.Reader, cmd []io.ReadWriter, err error) string {
 	return b.String()
 }
 
-// Pipe pipes I/O streams together.
-func Pipe(src io.Reader, cmd ...io.ReadWriter) error {
-	pipeTrace(src, cmd)
-	var e any
-	for i := -1; i < len(cmd); i++ {
-		if i < 0 {
-			e = src
-		} else {
-			e = cmd[i]
-		}
-		if l, ok := e.(Logger); ok {
+// pipeError wraps an error returned by [Copy] in an [*Error].
+func pipeError(
+	src io.Reader, cmd []io.ReadWriter, r Result, err error, get bool,
+) error {
+	e := &Error{
+		Cmd:     pipeString(src, cmd),
+		Result:  r,
+		Err:     err,
+		pipe:    pipeErr(src, cmd, err),
+		get:     get,
+		secrets: pipeSecrets(src, cmd),
+	}
+	if cerr, ok := err.(copyError); ok {
+		e.Cmd = cmdString(stage(src, cmd, cerr.off))
+		e.Offset = cerr.off
+		e.Err = cerr.err
+	}
+	return e
+}
+
+// stage returns the pipeline stage at index i, where src is at index 0.
+func stage(src io.Reader, cmd []io.ReadWriter, i int) any {
+	if i == 0 {
+		return src
+	}
+	return cmd[i-1]
+}
+
+// A Pipeline determines how failures in piped I/O streams are reported.
+//
+// By default, a pipeline fails if any of its stages fail, and the code of its
+// [Result] is that of the last stage.
+type Pipeline struct {
+	// LastStage, if true, causes a pipeline to fail only if its last stage
+	// fails. The code of its Result is that of the last stage.
+	LastStage bool
+	// Pipefail, if true, causes the code of a pipeline's Result to be that of
+	// the rightmost stage with a non-zero code, like a bash pipeline with the
+	// pipefail option set. It has no effect if LastStage is set.
+	Pipefail bool
+
+	// Progress, if non-nil, is called periodically while the pipeline runs
+	// and once more when it finishes. Calls are not made concurrently.
+	Progress func(Progress)
+	// Interval is the time between progress reports.
+	// If zero, progress is reported every second.
+	Interval time.Duration
+}
+
+// Run pipes I/O streams together.
+//
+// If the pipeline fails, the returned error is an [*Error].
+func (p Pipeline) Run(src io.Reader, cmd ...io.ReadWriter) error {
+	spans := tracePipeline(src, cmd)
+	for i := 0; i <= len(cmd); i++ {
+		if l, ok := stage(src, cmd, i).(Logger); ok {
 			l.Log(os.Stderr)
 		}
 	}
-	_, err := Copy(nopCloser{os.Stdout}, src, cmd...)
+	counts := make([]atomic.Int64, len(cmd)+1)
+	_, errs, err := p.copy(nopCloser{os.Stdout}, src, cmd, counts)
+	traceEnd(spans, src, cmd, errs, counts)
+	r, err := p.result(src, cmd, errs, nil, err)
+	if err != nil {
+		return pipeError(src, cmd, r, err, false)
+	}
+	return nil
+}
+
+// Get pipes I/O streams together and captures the output in a [Result].
+//
+// If the pipeline fails, the returned error is an [*Error].
+func (p Pipeline) Get(src io.Reader, cmd ...io.ReadWriter) (Result, error) {
+	spans := tracePipeline(src, cmd)
+	var (
+		dst    bytes.Buffer
+		log    syncBuffer
+		logs   = make([]*syncBuffer, len(cmd)+1)
+		counts = make([]atomic.Int64, len(cmd)+1)
+	)
+	for i := range logs {
+		logs[i] = new(syncBuffer)
+		if l, ok := stage(src, cmd, i).(Logger); ok {
+			l.Log(io.MultiWriter(&log, logs[i]))
+		}
+	}
+	_, errs, err := p.copy(&dst, src, cmd, counts)
+	traceEnd(spans, src, cmd, errs, counts)
+	r, err := p.result(src, cmd, errs, logs, err)
+	r.Out = strings.TrimRight(dst.String(), "\n")
+	r.Log = strings.TrimRight(log.String(), "\n")
 	if err != nil {
-		err = fmt.Errorf("%w\n\n%s", err, pipeErr(src, cmd, err))
+		return r, pipeError(src, cmd, r, err, true)
+	}
+	return r, nil
+}
+
+// result summarizes the stages of a completed pipeline and determines its
+// error.
+func (p Pipeline) result(
+	src io.Reader, cmd []io.ReadWriter,
+	errs []error, logs []*syncBuffer, err error,
+) (r Result, _ error) {
+	last := len(cmd)
+	r.Cmd = readWriter(stage(src, cmd, last))
+	r.Stages = make([]Stage, last+1)
+	for i := range r.Stages {
+		e := stage(src, cmd, i)
+		s := &r.Stages[i]
+		s.Cmd = cmdString(e)
+		s.Err = errs[i]
+		if logs != nil {
+			s.Log = strings.TrimRight(logs[i].String(), "\n")
+		}
+		if c, ok := e.(Coder); ok {
+			s.Code = c.Code()
+		}
+	}
+	r.Code = r.Stages[last].Code
+	if p.LastStage {
+		if errs[last] != nil {
+			return r, copyError{errs[last], last}
+		}
+		if cerr, ok := err.(copyError); ok && errs[cerr.off] != nil {
+			return r, nil // Other stages may fail.
+		}
+		return r, err
+	}
+	for i := last; p.Pipefail && i >= 0; i-- {
+		if r.Stages[i].Code != 0 {
+			r.Code = r.Stages[i].Code
+			break
+		}
+	}
+	for i := last; i >= 0; i-- {
+		if errs[i] != nil {
+			return r, copyError{errs[i], i}
+		}
 	}
-	return err
+	return r, err
+}
+
+// Pipe pipes I/O streams together.
+//
+// Pipe is equivalent to Pipeline{}.Run.
+func Pipe(src io.Reader, cmd ...io.ReadWriter) error {
+	return Pipeline{}.Run(src, cmd...)
 }
 
 type nopCloser struct{ io.Writer }
@@ -89,36 +211,10 @@ func MustPipe(src io.Reader, cmd ...io.ReadWriter) {
 }
 
 // GetPipe pipes I/O streams together and captures the output in a [Result].
+//
+// GetPipe is equivalent to Pipeline{}.Get.
 func GetPipe(src io.Reader, cmd ...io.ReadWriter) (Result, error) {
-	pipeTrace(src, cmd)
-	var (
-		dst bytes.Buffer
-		log syncBuffer
-		e   any
-		r   Result
-	)
-	for i := -1; i < len(cmd); i++ {
-		if i < 0 {
-			e = src
-		} else {
-			e = cmd[i]
-		}
-		if l, ok := e.(Logger); ok {
-			l.Log(&log)
-		}
-	}
-	_, err := Copy(&dst, src, cmd...)
-	r.Out = strings.TrimRight(dst.String(), "\n")
-	r.Log = strings.TrimRight(log.String(), "\n")
-	r.Cmd = readWriter(e)
-	if c, ok := r.Cmd.(Coder); ok {
-		r.Code = c.Code()
-	}
-	if err != nil {
-		err = fmt.Errorf("%w\n\n%s\n\nout:%slog:%scode: %d",
-			err, pipeErr(src, cmd, err), fmtout(r.Out), fmtout(r.Log), r.Code)
-	}
-	return r, err
+	return Pipeline{}.Get(src, cmd...)
 }
 
 // MustGetPipe pipes I/O streams together and captures the output in a
@@ -3,12 +3,15 @@ package cmdio_test
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"testing"
 	"testing/iotest"
 
 	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
 	"lesiw.io/cmdio/sys"
 	"lesiw.io/prefix"
 )
@@ -109,3 +112,104 @@ func ExampleMustGetPipe_panic() {
 	// 	ls: /bad_directory: No such file or directory
 	// code: 0
 }
+
+func ExamplePipeline_lastStage() {
+	rnr := sys.Runner()
+	r, err := cmdio.Pipeline{LastStage: true}.Get(
+		rnr.Command("false"),
+		rnr.Command("echo", "hello world"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("out:", r.Out)
+	fmt.Println("code:", r.Code)
+	for _, s := range r.Stages {
+		fmt.Printf("stage: %s (code %d)\n", s.Cmd, s.Code)
+	}
+	// Output:
+	// out: hello world
+	// code: 0
+	// stage: false (code 1)
+	// stage: echo 'hello world' (code 0)
+}
+
+func TestPipelineStages(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"grep", "..."}, cmdiotest.Response{
+		Log:  "no match",
+		Code: 1,
+	})
+	cdr.Handle([]string{"wc", "-l"}, cmdiotest.Response{Out: "0"})
+
+	tests := []struct {
+		pipeline cmdio.Pipeline
+		code     int
+		fail     bool
+	}{
+		{cmdio.Pipeline{}, 0, true},
+		{cmdio.Pipeline{Pipefail: true}, 1, true},
+		{cmdio.Pipeline{LastStage: true}, 0, false},
+	}
+	for _, tt := range tests {
+		r, err := tt.pipeline.Get(
+			strings.NewReader("hello world"),
+			rnr.Command("grep", "goodbye"),
+			rnr.Command("wc", "-l"),
+		)
+		if got, want := err != nil, tt.fail; got != want {
+			t.Errorf("%+v.Get().error = %v, want failure: %v",
+				tt.pipeline, err, want)
+		}
+		if got, want := r.Code, tt.code; got != want {
+			t.Errorf("%+v.Get().Result.Code = %d, want %d",
+				tt.pipeline, got, want)
+		}
+		if got, want := len(r.Stages), 3; got != want {
+			t.Fatalf("len(Result.Stages) = %d, want %d", got, want)
+		}
+		grep := r.Stages[1]
+		if got, want := grep.Cmd, "grep goodbye"; got != want {
+			t.Errorf("Stages[1].Cmd = %q, want %q", got, want)
+		}
+		if got, want := grep.Log, "no match"; got != want {
+			t.Errorf("Stages[1].Log = %q, want %q", got, want)
+		}
+		if got, want := grep.Code, 1; got != want {
+			t.Errorf("Stages[1].Code = %d, want %d", got, want)
+		}
+		if grep.Err == nil {
+			t.Errorf("Stages[1].Err = <nil>, want error")
+		}
+		if got := r.Stages[2].Err; got != nil {
+			t.Errorf("Stages[2].Err = %q, want <nil>", got)
+		}
+	}
+}
+
+func TestPipelineLastStageCloseError(t *testing.T) {
+	pr, pw := io.Pipe()
+	stage := &closeErrPipe{pr, pw}
+
+	_, err := cmdio.Pipeline{LastStage: true}.Get(
+		strings.NewReader("hello world"), stage,
+	)
+
+	if !errors.Is(err, errCloseFailed) {
+		t.Errorf("Get() = %v, want %v", err, errCloseFailed)
+	}
+}
+
+var errCloseFailed = errors.New("close failed")
+
+// closeErrPipe is a pipeline stage that fails to close.
+type closeErrPipe struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (p *closeErrPipe) Close() error {
+	_ = p.PipeWriter.Close()
+	return errCloseFailed
+}
@@ -0,0 +1,129 @@
+package cmdio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"lesiw.io/cmdio/internal/pattern"
+)
+
+// A Rule inspects a command before it is instantiated by a [Runner]. It
+// returns the arguments to instantiate the command with, which may differ
+// from args, or an error if the command must not be executed.
+//
+// The env passed to a Rule must not be modified.
+type Rule func(env map[string]string, args []string) ([]string, error)
+
+// A PolicyError describes a command rejected by a [Rule].
+//
+// Commands that are rejected fail without being executed, and the functions
+// that execute them return an [*Error] wrapping the PolicyError.
+type PolicyError struct {
+	// Args are the arguments of the rejected command, as passed to the Rule.
+	Args []string
+	// Err is the error returned by the Rule.
+	Err error
+}
+
+func (e *PolicyError) Error() string {
+	return "rejected by policy: " + e.Err.Error()
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// WithRule creates a new Runner that applies rules to each command before it
+// is instantiated, in the order they were added. Rules apply regardless of
+// the [Commander] or of any Runner registered with [Runner.WithCommand], but
+// not to commands instantiated by calling the Runner's Commander directly.
+// The new Runner will otherwise be identical to its parent.
+func (rnr *Runner) WithRule(rules ...Rule) *Runner {
+	rnr2 := rnr.clone()
+	rnr2.opts.rules = append(slices.Clip(rnr.opts.rules), rules...)
+	return rnr2
+}
+
+// Rules returns the rules added to the Runner with [Runner.WithRule].
+func (rnr *Runner) Rules() []Rule {
+	return slices.Clip(rnr.opts.rules)
+}
+
+// check applies the rules of rnr to args.
+func (rnr *Runner) check(args []string) ([]string, *PolicyError) {
+	for _, rule := range rnr.opts.rules {
+		args2, err := rule(rnr.env, slices.Clone(args))
+		if err != nil {
+			var perr *PolicyError
+			if !errors.As(err, &perr) {
+				perr = &PolicyError{Args: args, Err: err}
+			}
+			return nil, perr
+		}
+		args = args2
+	}
+	return args, nil
+}
+
+// AllowCommands returns a [Rule] that rejects commands whose name is not
+// one of names. Names are compared exactly, so allowing "ls" does not allow
+// "/bin/ls".
+func AllowCommands(names ...string) Rule {
+	return func(_ map[string]string, args []string) ([]string, error) {
+		if len(args) == 0 || !slices.Contains(names, args[0]) {
+			return nil, fmt.Errorf("command not allowed")
+		}
+		return args, nil
+	}
+}
+
+// DenyArgs returns a [Rule] that rejects commands whose arguments match the
+// pattern p, as in [lesiw.io/cmdio/cmdiotest.Commander.Handle].
+func DenyArgs(p ...string) Rule {
+	return func(_ map[string]string, args []string) ([]string, error) {
+		if pattern.Match(p, args) {
+			return nil, fmt.Errorf("arguments match %q", p)
+		}
+		return args, nil
+	}
+}
+
+// RequireDir returns a [Rule] that rejects commands unless their PWD is dir or
+// one of its subdirectories. Paths are compared lexically after cleaning, and
+// commands without a PWD are rejected.
+func RequireDir(dir string) Rule {
+	dir = path.Clean(dir)
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	return func(env map[string]string, args []string) ([]string, error) {
+		pwd, ok := env["PWD"]
+		if !ok {
+			return nil, fmt.Errorf("no PWD, want %s", dir)
+		}
+		pwd = path.Clean(pwd)
+		if pwd != dir && !strings.HasPrefix(pwd, prefix) {
+			return nil, fmt.Errorf("PWD %s is outside of %s", pwd, dir)
+		}
+		return args, nil
+	}
+}
+
+// rejectedCmd is a command that failed a Rule.
+type rejectedCmd struct {
+	secrets []string
+	err     *PolicyError
+}
+
+func (c rejectedCmd) Read([]byte) (int, error)  { return 0, c.err }
+func (c rejectedCmd) Write([]byte) (int, error) { return 0, c.err }
+func (c rejectedCmd) Close() error              { return c.err }
+func (c rejectedCmd) Attach() error             { return c.err }
+func (c rejectedCmd) Code() int                 { return 0 }
+func (c rejectedCmd) Log(io.Writer)             {}
+
+func (c rejectedCmd) String() string {
+	return redact(c.secrets, strings.Join(c.err.Args, " "))
+}
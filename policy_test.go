@@ -0,0 +1,154 @@
+package cmdio_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+)
+
+func TestRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule cmdio.Rule
+		env  map[string]string
+		args []string
+		ok   bool
+	}{{
+		name: "allowed command",
+		rule: cmdio.AllowCommands("ls", "cat"),
+		args: []string{"cat", "file"},
+		ok:   true,
+	}, {
+		name: "disallowed command",
+		rule: cmdio.AllowCommands("ls", "cat"),
+		args: []string{"rm", "file"},
+	}, {
+		name: "disallowed path",
+		rule: cmdio.AllowCommands("ls"),
+		args: []string{"/bin/ls"},
+	}, {
+		name: "denied args",
+		rule: cmdio.DenyArgs("rm", "-rf", "/", "..."),
+		args: []string{"rm", "-rf", "/"},
+	}, {
+		name: "denied args with trailing",
+		rule: cmdio.DenyArgs("rm", "-rf", "/", "..."),
+		args: []string{"rm", "-rf", "/", "tmp"},
+	}, {
+		name: "other args",
+		rule: cmdio.DenyArgs("rm", "-rf", "/", "..."),
+		args: []string{"rm", "-rf", "/tmp/x"},
+		ok:   true,
+	}, {
+		name: "required dir",
+		rule: cmdio.RequireDir("/work"),
+		env:  map[string]string{"PWD": "/work"},
+		args: []string{"ls"},
+		ok:   true,
+	}, {
+		name: "required subdir",
+		rule: cmdio.RequireDir("/work/"),
+		env:  map[string]string{"PWD": "/work/src"},
+		args: []string{"ls"},
+		ok:   true,
+	}, {
+		name: "sibling dir",
+		rule: cmdio.RequireDir("/work"),
+		env:  map[string]string{"PWD": "/workshop"},
+		args: []string{"ls"},
+	}, {
+		name: "escaped dir",
+		rule: cmdio.RequireDir("/work"),
+		env:  map[string]string{"PWD": "/work/../etc"},
+		args: []string{"ls"},
+	}, {
+		name: "no dir",
+		rule: cmdio.RequireDir("/work"),
+		args: []string{"ls"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swap(t, &cmdio.Trace, io.Discard)
+			rnr, cdr := cmdiotest.New()
+			cdr.Handle([]string{"..."}, cmdiotest.Response{})
+
+			err := rnr.WithEnv(tt.env).WithRule(tt.rule).Run(tt.args...)
+
+			if tt.ok {
+				if err != nil {
+					t.Errorf("Run() = %q, want <nil>", err)
+				}
+				return
+			}
+			var perr *cmdio.PolicyError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Run() = %v, want *cmdio.PolicyError", err)
+			}
+			if got, want := perr.Args, tt.args; !cmp.Equal(got, want) {
+				t.Errorf("PolicyError.Args = %q, want %q", got, want)
+			}
+			if calls := cdr.Calls(); len(calls) > 0 {
+				t.Errorf("Calls() = %v, want none", calls)
+			}
+		})
+	}
+}
+
+func TestRuleRewrite(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"..."}, cmdiotest.Response{})
+	rnr = rnr.WithRule(
+		cmdio.DenyArgs("rm", "-f", "..."),
+		func(_ map[string]string, args []string) ([]string, error) {
+			if len(args) > 0 && args[0] == "rm" {
+				return append([]string{"rm", "-i"}, args[1:]...), nil
+			}
+			return args, nil
+		},
+	)
+
+	if err := rnr.Run("rm", "file"); err != nil {
+		t.Fatalf("Run() = %q, want <nil>", err)
+	}
+	err := rnr.Run("rm", "-f", "file")
+
+	var perr *cmdio.PolicyError
+	if !errors.As(err, &perr) {
+		t.Errorf("Run() = %v, want *cmdio.PolicyError", err)
+	}
+	got := cdr.Calls()
+	want := []cmdiotest.Call{{Args: []string{"rm", "-i", "file"}}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Calls() -want +got\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestRuleWithCommand(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	rnr2, cdr2 := cmdiotest.New()
+	cdr2.Handle([]string{"..."}, cmdiotest.Response{})
+	rnr = rnr.WithCommand("git", rnr2).
+		WithRule(cmdio.DenyArgs("git", "push", "..."))
+
+	if err := rnr.Run("git", "status"); err != nil {
+		t.Errorf("Run(git status) = %q, want <nil>", err)
+	}
+	err := rnr.Run("git", "push", "--force")
+
+	var perr *cmdio.PolicyError
+	if !errors.As(err, &perr) {
+		t.Errorf("Run(git push) = %v, want *cmdio.PolicyError", err)
+	}
+	if got, want := len(cdr2.Calls()), 1; got != want {
+		t.Errorf("len(Calls()) = %d, want %d", got, want)
+	}
+	if calls := cdr.Calls(); len(calls) > 0 {
+		t.Errorf("Calls() = %v, want none", calls)
+	}
+}
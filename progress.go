@@ -0,0 +1,94 @@
+package cmdio
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Progress describes the progress of a pipeline.
+type Progress struct {
+	// Elapsed is the time since the pipeline started.
+	Elapsed time.Duration
+	// Stages describes the output of each stage of the pipeline, starting
+	// with its source.
+	Stages []StageProgress
+	// Done is true for the final report, made once the pipeline finishes.
+	Done bool
+}
+
+// StageProgress describes the output of one stage of a pipeline.
+type StageProgress struct {
+	Bytes int64   // Bytes read from the stage so far.
+	Rate  float64 // Bytes per second read since the previous report.
+}
+
+// copy copies the stages of a pipeline, counting the bytes read from each
+// stage in counts and reporting progress if requested.
+func (p Pipeline) copy(
+	dst io.Writer, src io.Reader, mid []io.ReadWriter, counts []atomic.Int64,
+) (int64, []error, error) {
+	if p.Progress == nil {
+		return copyStages(dst, src, mid, counts)
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	var (
+		prev   = make([]int64, len(counts))
+		start  = time.Now()
+		last   = start
+		done   = make(chan struct{})
+		exited = make(chan struct{})
+	)
+	report := func(final bool) {
+		now := time.Now()
+		dt := now.Sub(last).Seconds()
+		pr := Progress{
+			Elapsed: now.Sub(start),
+			Stages:  make([]StageProgress, len(counts)),
+			Done:    final,
+		}
+		for i := range counts {
+			n := counts[i].Load()
+			pr.Stages[i].Bytes = n
+			if dt > 0 {
+				pr.Stages[i].Rate = float64(n-prev[i]) / dt
+			}
+			prev[i] = n
+		}
+		last = now
+		p.Progress(pr)
+	}
+	go func() {
+		defer close(exited)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				report(false)
+			case <-done:
+				report(true)
+				return
+			}
+		}
+	}()
+	written, errs, err := copyStages(dst, src, mid, counts)
+	close(done)
+	<-exited
+	return written, errs, err
+}
+
+// Copy is like [Copy], but it reports progress as configured by the
+// Pipeline, and its error, if any, is determined as it would be for a
+// pipeline of commands.
+func (p Pipeline) Copy(
+	dst io.Writer, src io.Reader, mid ...io.ReadWriter,
+) (written int64, err error) {
+	counts := make([]atomic.Int64, len(mid)+1)
+	written, errs, err := p.copy(dst, src, mid, counts)
+	_, err = p.result(src, mid, errs, nil, err)
+	return written, err
+}
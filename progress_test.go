@@ -0,0 +1,79 @@
+package cmdio_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+func TestPipelineProgress(t *testing.T) {
+	rnr := sys.Runner()
+	var (
+		buf     bytes.Buffer
+		reports []cmdio.Progress
+	)
+	p := cmdio.Pipeline{
+		Progress: func(pr cmdio.Progress) { reports = append(reports, pr) },
+		Interval: 10 * time.Millisecond,
+	}
+
+	n, err := p.Copy(&buf,
+		rnr.Command("sh", "-c", "echo a; sleep 0.1; echo b"),
+		rnr.Command("cat"),
+	)
+
+	if err != nil {
+		t.Fatalf("Pipeline.Copy() err = %v", err)
+	}
+	if got, want := n, int64(8); got != want {
+		t.Errorf("Pipeline.Copy() = %d, want %d", got, want)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("got %d progress reports, want at least 2", len(reports))
+	}
+	final := reports[len(reports)-1]
+	if !final.Done {
+		t.Errorf("final Progress.Done = false, want true")
+	}
+	var got []int64
+	for _, s := range final.Stages {
+		got = append(got, s.Bytes)
+	}
+	if want := []int64{4, 4}; !cmp.Equal(got, want) {
+		t.Errorf("final Progress bytes = %v, want %v", got, want)
+	}
+	for _, pr := range reports[:len(reports)-1] {
+		if pr.Done {
+			t.Errorf("intermediate Progress.Done = true, want false")
+		}
+	}
+}
+
+func TestPipelineProgressGet(t *testing.T) {
+	var reports []cmdio.Progress
+	p := cmdio.Pipeline{
+		Progress: func(pr cmdio.Progress) { reports = append(reports, pr) },
+	}
+	swap(t, &cmdio.Trace, io.Discard)
+
+	r, err := p.Get(strings.NewReader("hello"), sys.Runner().Command("cat"))
+
+	if err != nil {
+		t.Fatalf("Pipeline.Get() err = %v", err)
+	}
+	if got, want := r.Out, "hello"; got != want {
+		t.Errorf("Pipeline.Get().Out = %q, want %q", got, want)
+	}
+	if got, want := len(reports), 1; got != want {
+		t.Fatalf("got %d progress reports, want %d", got, want)
+	}
+	if got, want := reports[0].Stages[1].Bytes, int64(5); got != want {
+		t.Errorf("Progress.Stages[1].Bytes = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"lesiw.io/cmdio"
+)
+
+type recorder struct {
+	cdr cmdio.Commander
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (r *recorder) Command(
+	ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	c := &cmd{
+		Command: r.cdr.Command(ctx, env, args...),
+		ctx:     ctx,
+		rec:     r,
+		entry: Entry{
+			Args: slices.Clone(args),
+			Env:  maps.Clone(env),
+		},
+	}
+	c.Command.Log(&c.log)
+	c.begin = sync.OnceFunc(c.beginFunc)
+	c.end = sync.OnceFunc(c.endFunc)
+	return c
+}
+
+func (r *recorder) Close() error {
+	if closer, ok := r.cdr.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (r *recorder) write(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(e) // Best effort.
+}
+
+type cmd struct {
+	cmdio.Command
+
+	ctx   context.Context
+	rec   *recorder
+	entry Entry
+	begin func()
+	end   func()
+	err   error
+	wrote bool // Whether the command has been written to.
+
+	in  syncBuffer
+	out bytes.Buffer
+	log syncBuffer
+}
+
+func (c *cmd) beginFunc() {
+	c.entry.Start = time.Now()
+}
+
+func (c *cmd) endFunc() {
+	c.entry.Duration = time.Since(c.entry.Start)
+	c.entry.In = c.in.Bytes()
+	c.entry.Out = bytes.Clone(c.out.Bytes())
+	c.entry.Log = c.log.Bytes()
+	c.entry.Code = c.Command.Code()
+	if c.err != nil && c.err != io.EOF {
+		c.entry.Err = c.err.Error()
+	}
+	c.rec.write(&c.entry)
+}
+
+func (c *cmd) Context() context.Context {
+	return c.ctx
+}
+
+func (c *cmd) Write(p []byte) (int, error) {
+	c.begin()
+	c.wrote = true
+	n, err := c.Command.Write(p)
+	c.in.Write(p[:n])
+	return n, err
+}
+
+// Close closes the command. Closing a command that has been written to ends
+// its input, so it is recorded once its output has been read; otherwise, it
+// is recorded now, in case its output is never read to the end.
+func (c *cmd) Close() error {
+	err := c.Command.Close()
+	if !c.wrote {
+		c.begin()
+		c.end()
+	}
+	return err
+}
+
+func (c *cmd) Read(p []byte) (int, error) {
+	c.begin()
+	n, err := c.Command.Read(p)
+	c.out.Write(p[:n])
+	if err != nil {
+		c.err = err
+		c.end()
+	}
+	return n, err
+}
+
+func (c *cmd) Log(w io.Writer) {
+	c.Command.Log(io.MultiWriter(&c.log, w))
+}
+
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (w *syncBuffer) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *syncBuffer) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return bytes.Clone(w.buf.Bytes())
+}
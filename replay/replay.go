@@ -0,0 +1,115 @@
+// Package replay records command sessions and replays them without executing
+// anything.
+//
+// A session is recorded as a sequence of JSON-encoded [Entry] values, one per
+// line. Sessions recorded against one [cmdio.Runner], such as a container
+// runner, can be replayed where that runner is unavailable.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+	"lesiw.io/cmdio/internal/sh"
+)
+
+// An Entry records the execution of a command.
+type Entry struct {
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env,omitempty"`
+	In       []byte            `json:"in,omitempty"`
+	Out      []byte            `json:"out,omitempty"`
+	Log      []byte            `json:"log,omitempty"`
+	Code     int               `json:"code,omitempty"`
+	Err      string            `json:"err,omitempty"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration"`
+}
+
+// Record instantiates a [cmdio.Runner] that runs commands using the given
+// runner and records each of them to w.
+//
+// Output from attached commands goes directly to the terminal and is not
+// recorded. A command is recorded once its output has been read to the end,
+// or once it is closed without having been written to.
+//
+// The recording Runner hides any [cmdio.Enver], [cmdio.Environer], or
+// [cmdio.FileCopier] methods of rnr's Commander, so that environment lookups
+// and file copies run commands that are recorded and can be replayed.
+func Record(rnr *cmdio.Runner, w io.Writer) *cmdio.Runner {
+	return rnr.WithCommander(&recorder{
+		cdr: rnr.Commander,
+		enc: json.NewEncoder(w),
+	})
+}
+
+// Replay instantiates a [cmdio.Runner] that responds to commands with the
+// entries recorded in r instead of executing them.
+//
+// A command is answered by the first unused entry with the same arguments and
+// environment. Commands without a matching entry fail. If an entry recorded
+// standard input, the command fails unless it receives the same input.
+func Replay(r io.Reader) (*cmdio.Runner, error) {
+	var entries []*Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		e := new(Entry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("bad replay entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replay: %w", err)
+	}
+	var mu sync.Mutex
+	rnr, cdr := cmdiotest.New()
+	cdr.HandleFunc([]string{"..."}, func(c cmdiotest.Call) cmdiotest.Response {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, e := range entries {
+			if e == nil || !slices.Equal(e.Args, c.Args) ||
+				!maps.Equal(e.Env, c.Env) {
+				continue
+			}
+			entries[i] = nil
+			return response(e)
+		}
+		return cmdiotest.Response{
+			Err: fmt.Errorf("no recorded entry for %s",
+				sh.String(context.Background(), c.Env, c.Args)),
+		}
+	})
+	return rnr, nil
+}
+
+func response(e *Entry) cmdiotest.Response {
+	r := cmdiotest.Response{
+		Out:  string(e.Out),
+		Log:  string(e.Log),
+		Code: e.Code,
+	}
+	if e.Err != "" {
+		r.Err = errors.New(e.Err)
+	}
+	if len(e.In) > 0 {
+		r.In = func(in string) error {
+			if in != string(e.In) {
+				return fmt.Errorf("input differs from recording: %q", in)
+			}
+			return nil
+		}
+	}
+	return r
+}
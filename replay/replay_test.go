@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+type session struct {
+	echo cmdio.Result
+	pipe cmdio.Result
+	fail cmdio.Result
+	errs []string
+}
+
+func run(t *testing.T, rnr *cmdio.Runner) (s session) {
+	t.Helper()
+	var err error
+	rnr = rnr.WithEnv(map[string]string{"PWD": "/tmp"})
+	if s.echo, err = rnr.Get("echo", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	s.pipe, err = cmdio.GetPipe(
+		strings.NewReader("hello world"),
+		rnr.Command("tr", "a-z", "A-Z"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.fail, err = rnr.Get("sh", "-c", "echo out; echo log >&2; exit 3")
+	if err == nil {
+		t.Fatal("rnr.Get(sh).error = <nil>, want error")
+	}
+	s.errs = append(s.errs, err.Error())
+	return
+}
+
+func TestReplay(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	var buf bytes.Buffer
+
+	want := run(t, Record(sys.Runner(), &buf))
+	rnr, err := Replay(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := run(t, rnr)
+
+	opts := []cmp.Option{
+		cmp.AllowUnexported(session{}),
+		cmpopts.IgnoreFields(cmdio.Result{}, "Cmd"),
+	}
+	if !cmp.Equal(got, want, opts...) {
+		t.Errorf("replayed session -want +got\n%s",
+			cmp.Diff(want, got, opts...))
+	}
+	if got, want := got.fail.Code, 3; got != want {
+		t.Errorf("replayed code = %d, want %d", got, want)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	var buf bytes.Buffer
+
+	_, err := cmdio.GetPipe(
+		strings.NewReader("hello world"),
+		Record(sys.Runner(), &buf).Command("tr", "a-z", "A-Z"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnr, err := Replay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rnr.Get("echo", "hello world"); err == nil {
+		t.Errorf("rnr.Get(unrecorded).error = <nil>, want error")
+	}
+	_, err = cmdio.GetPipe(
+		strings.NewReader("goodbye world"),
+		rnr.Command("tr", "a-z", "A-Z"),
+	)
+	if err == nil {
+		t.Errorf("GetPipe(different input).error = <nil>, want error")
+	}
+}
+
+func TestRecordClosed(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	var buf bytes.Buffer
+
+	cmd := Record(sys.Runner(), &buf).Command("true")
+	if err := cmd.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	rnr, err := Replay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rnr.Get("true"); err != nil {
+		t.Errorf("rnr.Get(closed command) = %v, want <nil>", err)
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
@@ -8,4 +8,20 @@ type Result struct {
 	Out  string
 	Log  string
 	Code int
+
+	// Combined holds standard output and standard error in the order they
+	// were produced. It is only populated by [Runner.GetCombined].
+	Combined []Chunk
+
+	// Stages describes each stage of a pipeline, starting with its source.
+	// It is only populated for pipelines.
+	Stages []Stage
+}
+
+// A Stage describes the outcome of one stage of a pipeline.
+type Stage struct {
+	Cmd  string // The stage, as formatted by its String method.
+	Log  string // Diagnostic output, if captured.
+	Code int    // Exit code, if the stage implements [Coder].
+	Err  error  // The error encountered reading from the stage, if any.
 }
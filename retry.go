@@ -0,0 +1,83 @@
+package cmdio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// A Retry describes how [Runner.Run], [Runner.Get], and [Runner.GetCombined]
+// retry failed commands. Each attempt executes a new command.
+type Retry struct {
+	// Attempts is the maximum number of attempts, including the first.
+	Attempts int
+	// Delay is how long to wait before the first retry. It doubles with each
+	// subsequent retry.
+	Delay time.Duration
+	// MaxDelay, if non-zero, limits the delay between attempts.
+	MaxDelay time.Duration
+	// If reports whether a failed attempt should be retried, given its Result
+	// and error. If nil, every failure is retried.
+	//
+	// Commands rejected by a [Rule] are never retried.
+	If func(Result, error) bool
+}
+
+// WithRetry creates a new Runner that retries failed commands according to r.
+// The new Runner will otherwise be identical to its parent.
+//
+// Retries stop early if the Runner's context is done.
+func (rnr *Runner) WithRetry(r Retry) *Runner {
+	rnr2 := rnr.clone()
+	rnr2.opts.retry = &r
+	return rnr2
+}
+
+// delay returns how long to wait before the nth retry, starting at 1.
+func (r *Retry) delay(n int) time.Duration {
+	d := r.Delay
+	for i := 1; i < n && (r.MaxDelay == 0 || d < r.MaxDelay); i++ {
+		if d > math.MaxInt64/2 {
+			break // Doubling would overflow.
+		}
+		d *= 2
+	}
+	if r.MaxDelay > 0 && d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	return d
+}
+
+// retry calls attempt until it succeeds or the Retry of rnr gives up.
+// Attempts are numbered from 1, or 0 if rnr does not retry commands.
+func (rnr *Runner) retry(
+	attempt func(n int) (Result, error),
+) (Result, error) {
+	r := rnr.opts.retry
+	if r == nil {
+		return attempt(0)
+	}
+	ctx := rnr.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	res, err := attempt(1)
+	for n := 2; err != nil && n <= r.Attempts; n++ {
+		if errors.As(err, new(*PolicyError)) {
+			break // Rejected commands cannot succeed.
+		}
+		if r.If != nil && !r.If(res, err) {
+			break
+		}
+		t := time.NewTimer(r.delay(n - 1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return res, err
+		case <-t.C:
+		}
+		res, err = attempt(n)
+	}
+	return res, err
+}
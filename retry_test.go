@@ -0,0 +1,152 @@
+package cmdio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
+)
+
+// flaky returns a Runner whose "fetch" command fails with code 1 until it
+// has been called n times.
+func flaky(n int) (*cmdio.Runner, *atomic.Int64) {
+	rnr, cdr := cmdiotest.New()
+	var calls atomic.Int64
+	cdr.HandleFunc([]string{"fetch"},
+		func(cmdiotest.Call) cmdiotest.Response {
+			if calls.Add(1) < int64(n) {
+				return cmdiotest.Response{Log: "timeout", Code: 1}
+			}
+			return cmdiotest.Response{Out: "ok"}
+		},
+	)
+	return rnr, &calls
+}
+
+func TestRetry(t *testing.T) {
+	var log eventLog
+	swap[cmdio.Tracer](t, &cmdio.TraceHook, &log)
+	rnr, calls := flaky(3)
+
+	r, err := rnr.WithRetry(cmdio.Retry{Attempts: 5}).Get("fetch")
+
+	if err != nil {
+		t.Fatalf("Get() = %q, want <nil>", err)
+	}
+	if got, want := r.Out, "ok"; got != want {
+		t.Errorf("Get().Out = %q, want %q", got, want)
+	}
+	if got, want := calls.Load(), int64(3); got != want {
+		t.Errorf("calls = %d, want %d", got, want)
+	}
+	var got []int
+	for _, e := range log.events {
+		if e.Kind == cmdio.Start {
+			got = append(got, e.Attempt)
+		}
+	}
+	if want := []int{1, 2, 3}; !cmp.Equal(got, want) {
+		t.Errorf("Event.Attempt = %v, want %v", got, want)
+	}
+}
+
+func TestRetryTrace(t *testing.T) {
+	var buf bytes.Buffer
+	swap[io.Writer](t, &cmdio.Trace, &buf)
+	rnr, _ := flaky(2)
+
+	_, err := rnr.WithRetry(cmdio.Retry{Attempts: 2}).Get("fetch")
+
+	if err != nil {
+		t.Fatalf("Get() = %q, want <nil>", err)
+	}
+	if got, want := buf.String(), "fetch\nfetch (attempt 2)\n"; got != want {
+		t.Errorf("Trace = %q, want %q", got, want)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, calls := flaky(5)
+
+	err := rnr.WithRetry(cmdio.Retry{Attempts: 2}).Run("fetch")
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Run() = %v, want *cmdio.Error", err)
+	}
+	if got, want := e.Result.Code, 1; got != want {
+		t.Errorf("Error.Result.Code = %d, want %d", got, want)
+	}
+	if got, want := calls.Load(), int64(2); got != want {
+		t.Errorf("calls = %d, want %d", got, want)
+	}
+}
+
+func TestRetryIf(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, calls := flaky(5)
+
+	_, err := rnr.WithRetry(cmdio.Retry{
+		Attempts: 5,
+		If: func(r cmdio.Result, _ error) bool {
+			return r.Log != "timeout"
+		},
+	}).Get("fetch")
+
+	if err == nil {
+		t.Errorf("Get() = <nil>, want error")
+	}
+	if got, want := calls.Load(), int64(1); got != want {
+		t.Errorf("calls = %d, want %d", got, want)
+	}
+}
+
+func TestRetryRejected(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, calls := flaky(5)
+
+	start := time.Now()
+	_, err := rnr.WithRule(cmdio.DenyArgs("fetch")).
+		WithRetry(cmdio.Retry{Attempts: 5, Delay: time.Minute}).
+		Get("fetch")
+
+	if !errors.As(err, new(*cmdio.PolicyError)) {
+		t.Errorf("Get() = %v, want *cmdio.PolicyError", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Get() took %v, want no retries", d)
+	}
+	if got, want := calls.Load(), int64(0); got != want {
+		t.Errorf("calls = %d, want %d", got, want)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, calls := flaky(5)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := rnr.WithContext(ctx).
+		WithRetry(cmdio.Retry{Attempts: 5, Delay: time.Minute}).
+		Get("fetch")
+
+	if err == nil {
+		t.Errorf("Get() = <nil>, want error")
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Get() took %v, want cancellation", d)
+	}
+	if got, want := calls.Load(), int64(1); got != want {
+		t.Errorf("calls = %d, want %d", got, want)
+	}
+}
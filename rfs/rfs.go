@@ -0,0 +1,413 @@
+// Package rfs provides a file system backed by a [cmdio.Runner].
+//
+// By default, files are accessed by running POSIX utilities, such as find and
+// cat, through the Runner. Files are also described by stat -c, which only GNU
+// coreutils and BusyBox provide, so systems such as BSD and macOS are not
+// supported. Commanders with native access to their file systems may implement
+// [Provider] instead.
+package rfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"lesiw.io/cmdio"
+)
+
+// A WriteFS is a file system that can be modified.
+type WriteFS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+
+	// WriteFile writes data to the named file, creating it with permissions
+	// perm if it does not exist, as [os.WriteFile] does.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// MkdirAll creates a directory, along with any necessary parents, as
+	// [os.MkdirAll] does.
+	MkdirAll(name string, perm fs.FileMode) error
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// Chmod changes the mode of the named file.
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// A Provider is a [cmdio.Commander] with native access to the file system it
+// runs commands on.
+//
+// FS returns the file tree rooted at the absolute path dir. Names passed to
+// the returned WriteFS have been checked with [fs.ValidPath].
+type Provider interface {
+	FS(dir string) WriteFS
+}
+
+// An FS is the file tree rooted at a directory on the system that a Runner
+// runs commands on. It implements [WriteFS].
+type FS struct {
+	rnr *cmdio.Runner
+	dir string
+	fs  WriteFS // Native implementation, if any.
+}
+
+var _ WriteFS = (*FS)(nil)
+
+// New returns the file tree rooted at dir on the system that rnr runs commands
+// on, in the manner of [os.DirFS]. If dir is relative, it is relative to the
+// PWD of rnr.
+//
+// If rnr has rules, files are always accessed by running commands, so that
+// the rules apply.
+func New(rnr *cmdio.Runner, dir string) (*FS, error) {
+	f := &FS{rnr: rnr, dir: dir}
+	if p, ok := rnr.Commander.(Provider); ok && len(rnr.Rules()) == 0 {
+		if !path.IsAbs(dir) {
+			r, err := rnr.Get("pwd")
+			if err != nil {
+				return nil, err
+			}
+			dir = path.Join(r.Out, dir)
+		}
+		f.fs = p.FS(dir)
+	}
+	return f, nil
+}
+
+// path validates name and returns its path on the Runner's system.
+func (f *FS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(f.dir, name), nil
+}
+
+// error describes the failure of op on name, reporting [fs.ErrNotExist] if
+// the file does not exist.
+func (f *FS) error(op, name, p string, err error) error {
+	const script = `[ -e "$1" ] || [ -L "$1" ]`
+	if _, serr := f.rnr.Get("sh", "-c", script, "-", p); serr != nil {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// Open opens the named file for reading. Unless the Runner's Commander is a
+// [Provider], the contents of files are read when they are opened.
+func (f *FS) Open(name string) (fs.File, error) {
+	if _, err := f.path("open", name); err != nil {
+		return nil, err
+	}
+	if f.fs != nil {
+		return f.fs.Open(name)
+	}
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: unwrap(err)}
+	}
+	if info.IsDir() {
+		entries, err := f.ReadDir(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: unwrap(err)}
+		}
+		return &dir{info: info, entries: entries}, nil
+	}
+	data, err := f.ReadFile(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: unwrap(err)}
+	}
+	return &file{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+// Stat returns a [fs.FileInfo] describing the named file, following symbolic
+// links.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.fs != nil {
+		return f.fs.Stat(name)
+	}
+	r, err := f.rnr.Get("stat", "-L", "-c", statFormat, p)
+	if err != nil {
+		return nil, f.error("stat", name, p, err)
+	}
+	info, err := parseStat(r.Out)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info.name = path.Base(name)
+	return info, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by
+// filename.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.fs != nil {
+		return f.fs.ReadDir(name)
+	}
+	var entries []fs.DirEntry
+	for record, err := range f.rnr.Scan(scanRecords,
+		"find", p, "-mindepth", "1", "-maxdepth", "1",
+		"-exec", "stat", "-c", statFormat, "{}", "+",
+	) {
+		if err != nil {
+			return nil, f.error("readdir", name, p, err)
+		}
+		info, err := parseStat(string(record))
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// ReadFile reads the named file and returns its contents.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.fs != nil {
+		return f.fs.ReadFile(name)
+	}
+	var data []byte
+	for chunk, err := range f.rnr.Scan(scanChunks, "cat", p) {
+		if err != nil {
+			return nil, f.error("open", name, p, err)
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
+
+// WriteFile writes data to the named file, creating it with permissions perm
+// if it does not exist. Otherwise, the file is truncated without changing its
+// permissions.
+func (f *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p, err := f.path("open", name)
+	if err != nil {
+		return err
+	}
+	if f.fs != nil {
+		return f.fs.WriteFile(name, data, perm)
+	}
+	const script = `[ -e "$1" ] || { : >"$1" && chmod "$2" "$1"; } && cat >"$1"`
+	_, err = cmdio.GetPipe(bytes.NewReader(data),
+		f.rnr.Command("sh", "-c", script, "-", p, octal(perm)))
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return nil
+}
+
+// MkdirAll creates the named directory with permissions perm, along with any
+// necessary parents.
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := f.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if f.fs != nil {
+		return f.fs.MkdirAll(name, perm)
+	}
+	if _, err := f.rnr.Get("mkdir", "-p", "-m", octal(perm), p); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (f *FS) Remove(name string) error {
+	p, err := f.path("remove", name)
+	if err != nil {
+		return err
+	}
+	if f.fs != nil {
+		return f.fs.Remove(name)
+	}
+	const script = `if [ -d "$1" ] && [ ! -L "$1" ]; then rmdir "$1"; ` +
+		`else rm "$1"; fi`
+	if _, err := f.rnr.Get("sh", "-c", script, "-", p); err != nil {
+		return f.error("remove", name, p, err)
+	}
+	return nil
+}
+
+// Chmod changes the mode of the named file to mode.
+func (f *FS) Chmod(name string, mode fs.FileMode) error {
+	p, err := f.path("chmod", name)
+	if err != nil {
+		return err
+	}
+	if f.fs != nil {
+		return f.fs.Chmod(name, mode)
+	}
+	if _, err := f.rnr.Get("chmod", octal(mode), p); err != nil {
+		return f.error("chmod", name, p, err)
+	}
+	return nil
+}
+
+// statFormat formats a file for parseStat: its mode in hexadecimal, size,
+// modification time, and name. Since names cannot contain slashes, a slash
+// ends each file's record, even if its name contains newlines.
+const statFormat = "%f %s %Y %n/"
+
+type fileInfo struct {
+	name  string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.mtime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+func parseStat(line string) (*fileInfo, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 4 || !strings.HasSuffix(fields[3], "/") {
+		return nil, fmt.Errorf("bad stat output: %q", line)
+	}
+	mode, err1 := strconv.ParseUint(fields[0], 16, 32)
+	size, err2 := strconv.ParseInt(fields[1], 10, 64)
+	mtime, err3 := strconv.ParseInt(fields[2], 10, 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("bad stat output: %q: %w", line, err)
+	}
+	return &fileInfo{
+		name:  path.Base(strings.TrimSuffix(fields[3], "/")),
+		size:  size,
+		mode:  fileMode(uint32(mode)),
+		mtime: time.Unix(mtime, 0),
+	}, nil
+}
+
+// fileMode converts a Unix mode to a [fs.FileMode].
+func fileMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0o777)
+	switch m & 0o170000 {
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	case 0o010000:
+		mode |= fs.ModeNamedPipe
+	case 0o140000:
+		mode |= fs.ModeSocket
+	case 0o020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o060000:
+		mode |= fs.ModeDevice
+	}
+	if m&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// octal formats the permission bits of mode for chmod.
+func octal(mode fs.FileMode) string {
+	m := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return fmt.Sprintf("%04o", m)
+}
+
+// scanChunks is a [bufio.SplitFunc] that returns data as it is read.
+func scanChunks(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	return len(data), data, nil
+}
+
+// scanRecords is a [bufio.SplitFunc] that returns the records formatted by
+// statFormat, one per line.
+func scanRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, []byte("/\n")); i >= 0 {
+		return i + 2, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// unwrap returns the underlying error of a [*fs.PathError].
+func unwrap(err error) error {
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		return perr.Err
+	}
+	return err
+}
+
+type file struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+type dir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{
+		Op: "read", Path: d.info.Name(), Err: errors.New("is a directory"),
+	}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,183 @@
+package rfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/rfs"
+	"lesiw.io/cmdio/sub"
+	"lesiw.io/cmdio/sys"
+)
+
+var runners = map[string]*cmdio.Runner{
+	"native": sys.Runner(),
+	// A sub runner is not an rfs.Provider.
+	"commands": sub.WithRunner(sys.Runner()),
+}
+
+func TestFS(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	for name, rnr := range runners {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			fsys, err := rfs.New(rnr, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fsys.MkdirAll("a/b", 0o755); err != nil {
+				t.Fatalf("MkdirAll() = %v", err)
+			}
+			err = fsys.WriteFile("a/b/c.txt", []byte("hello\n\n"), 0o640)
+			if err != nil {
+				t.Fatalf("WriteFile() = %v", err)
+			}
+			if err := fsys.WriteFile("d", nil, 0o600); err != nil {
+				t.Fatalf("WriteFile() = %v", err)
+			}
+			if err := fstest.TestFS(fsys, "a/b/c.txt", "d"); err != nil {
+				t.Error(err)
+			}
+
+			data, err := fsys.ReadFile("a/b/c.txt")
+			if err != nil {
+				t.Fatalf("ReadFile() = %v", err)
+			}
+			if got, want := string(data), "hello\n\n"; got != want {
+				t.Errorf("ReadFile() = %q, want %q", got, want)
+			}
+			info, err := fsys.Stat("a/b/c.txt")
+			if err != nil {
+				t.Fatalf("Stat() = %v", err)
+			}
+			if got, want := info.Mode(), fs.FileMode(0o640); got != want {
+				t.Errorf("Stat().Mode() = %v, want %v", got, want)
+			}
+			if got, want := info.Size(), int64(7); got != want {
+				t.Errorf("Stat().Size() = %d, want %d", got, want)
+			}
+
+			if err := fsys.Chmod("d", 0o755); err != nil {
+				t.Fatalf("Chmod() = %v", err)
+			}
+			info, err = fsys.Stat("d")
+			if err != nil {
+				t.Fatalf("Stat() = %v", err)
+			}
+			if got, want := info.Mode(), fs.FileMode(0o755); got != want {
+				t.Errorf("Stat().Mode() = %v, want %v", got, want)
+			}
+
+			if err := fsys.Remove("d"); err != nil {
+				t.Fatalf("Remove() = %v", err)
+			}
+			if _, err := fsys.Stat("d"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Stat() = %v, want fs.ErrNotExist", err)
+			}
+			if err := fsys.Remove("a"); err == nil {
+				t.Errorf("Remove(non-empty dir) = <nil>, want error")
+			}
+			_, err = fsys.ReadFile("missing")
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("ReadFile() = %v, want fs.ErrNotExist", err)
+			}
+			_, err = fsys.ReadFile("../escape")
+			if !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("ReadFile() = %v, want fs.ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestNewlineNames(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	for name, rnr := range runners {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range []string{"a\nb", "c"} {
+				err := os.WriteFile(filepath.Join(dir, f), nil, 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			fsys, err := rfs.New(rnr, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := fsys.ReadDir(".")
+
+			if err != nil {
+				t.Fatalf("ReadDir() = %v", err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			got, want := fmt.Sprintf("%q", names), `["a\nb" "c"]`
+			if got != want {
+				t.Errorf("ReadDir() names = %s, want %s", got, want)
+			}
+			if _, err := fsys.Stat("a\nb"); err != nil {
+				t.Errorf("Stat() = %v", err)
+			}
+		})
+	}
+}
+
+func TestRelative(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	dir := t.TempDir()
+	for name, rnr := range runners {
+		t.Run(name, func(t *testing.T) {
+			rnr := rnr.WithEnv(map[string]string{"PWD": dir})
+			if err := rnr.Run("mkdir", "-p", "sub"); err != nil {
+				t.Fatal(err)
+			}
+			if err := rnr.Run("touch", "sub/"+name); err != nil {
+				t.Fatal(err)
+			}
+			fsys, err := rfs.New(rnr, "sub")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := fsys.Stat(name); err != nil {
+				t.Errorf("Stat() = %v, want <nil>", err)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	dir := t.TempDir()
+	rnr := sys.Runner().WithRule(cmdio.AllowCommands("pwd"))
+	fsys, err := rfs.New(rnr, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fsys.WriteFile("x", []byte("x"), 0o644)
+
+	if perr := new(cmdio.PolicyError); !errors.As(err, &perr) {
+		t.Errorf("WriteFile() = %v, want PolicyError", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "x")); err == nil {
+		t.Errorf("WriteFile() wrote despite rule")
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
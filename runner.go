@@ -3,105 +3,173 @@ package cmdio
 import (
 	"bufio"
 	"context"
-	"fmt"
 	"io"
 	"maps"
+	"slices"
 	"strings"
+	"time"
 )
 
 // A Runner runs commands.
 type Runner struct {
-	ctx context.Context
-	env map[string]string
-	cmd map[string]*Runner
+	ctx  context.Context
+	env  map[string]string
+	cmd  map[string]*Runner
+	opts options
 	Commander
 }
 
+// options holds per-command settings other than the variables set in env.
+type options struct {
+	timeout time.Duration
+	term    *Termination
+	secrets []string
+	rules   []Rule
+	retry   *Retry
+	unset   []string // Sorted names of variables to unset.
+	clean   bool     // Whether commands inherit no variables from the host.
+}
+
+// clone returns a copy of rnr that does not share its maps.
+func (rnr *Runner) clone() *Runner {
+	rnr2 := *rnr
+	rnr2.env = maps.Clone(rnr.env)
+	rnr2.cmd = maps.Clone(rnr.cmd)
+	return &rnr2
+}
+
 // WithContext creates a new Runner with the provided [context.Context].
 // The new Runner will have a copy of the parent Runner's env
 // and shares the same commander as its parent.
 func (rnr *Runner) WithContext(ctx context.Context) *Runner {
-	return &Runner{
-		ctx,
-		maps.Clone(rnr.env),
-		maps.Clone(rnr.cmd),
-		rnr.Commander,
-	}
+	rnr2 := rnr.clone()
+	rnr2.ctx = ctx
+	return rnr2
 }
 
 // WithEnv creates a new Runner with the provided env.
 // The new Runner will share the same context and commander as its parent.
 //
-// PWD conventionally sets the working directory.
+// PWD conventionally sets the working directory. Variables with the value
+// [Unset] are unset.
 func (rnr *Runner) WithEnv(env map[string]string) *Runner {
-	var env2 map[string]string
-	if rnr.env == nil {
-		env2 = make(map[string]string)
-	} else {
-		env2 = maps.Clone(rnr.env)
-	}
+	rnr2 := rnr.clone()
 	for k, v := range env {
-		env2[k] = v
-	}
-	return &Runner{
-		rnr.ctx,
-		env2,
-		maps.Clone(rnr.cmd),
-		rnr.Commander,
+		if v == Unset {
+			delete(rnr2.env, k)
+			rnr2.opts.unset = addName(rnr2.opts.unset, k)
+			continue
+		}
+		if rnr2.env == nil {
+			rnr2.env = make(map[string]string)
+		}
+		rnr2.env[k] = v
+		rnr2.opts.unset = removeName(rnr2.opts.unset, k)
 	}
+	return rnr2
 }
 
 // WithCommander creates a new Runner with the provided [Commander].
 // The new Runner will have a copy of the parent Runner's env
 // and shares the same context as its parent.
 func (rnr *Runner) WithCommander(cdr Commander) *Runner {
-	return &Runner{
-		rnr.ctx,
-		maps.Clone(rnr.env),
-		maps.Clone(rnr.cmd),
-		cdr,
-	}
+	rnr2 := rnr.clone()
+	rnr2.Commander = cdr
+	return rnr2
 }
 
 // WithCommand creates a new Runner with cmd handled by the provided
 // [Runner].
 // The new Runner will otherwise be identical to its parent.
 func (rnr *Runner) WithCommand(cmd string, rnr2 *Runner) *Runner {
-	var cmd2 map[string]*Runner
-	if rnr.cmd == nil {
-		cmd2 = make(map[string]*Runner)
-	} else {
-		cmd2 = maps.Clone(rnr.cmd)
-	}
-	cmd2[cmd] = rnr2
-	return &Runner{
-		rnr.ctx,
-		maps.Clone(rnr.env),
-		cmd2,
-		rnr.Commander,
+	rnr3 := rnr.clone()
+	if rnr3.cmd == nil {
+		rnr3.cmd = make(map[string]*Runner)
 	}
+	rnr3.cmd[cmd] = rnr2
+	return rnr3
+}
+
+// WithoutCommands creates a new Runner that handles every command itself,
+// rather than the Runners provided to [Runner.WithCommand].
+// The new Runner will otherwise be identical to its parent.
+func (rnr *Runner) WithoutCommands() *Runner {
+	rnr2 := rnr.clone()
+	rnr2.cmd = nil
+	return rnr2
 }
 
 // Command instantiates a command as an [io.ReadWriter].
 //
 // The command will not be executed until the first time it is read or written
-// to.
+// to. If a [Rule] rejects the command, reading from or writing to it fails with
+// a [*PolicyError].
 func (rnr *Runner) Command(args ...string) io.ReadWriter {
 	ctx := rnr.ctx
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if len(rnr.opts.rules) > 0 {
+		var err *PolicyError
+		if args, err = rnr.check(args); err != nil {
+			return rejectedCmd{rnr.opts.secrets, err}
+		}
+	}
 	if len(args) > 0 && rnr.cmd != nil {
 		if rnr2, ok := rnr.cmd[args[0]]; ok {
-			return rnr2.WithContext(ctx).WithEnv(rnr.env).Command(args...)
+			rnr2 = rnr2.WithContext(ctx).
+				WithEnv(rnr.env).
+				WithoutEnv(rnr.opts.unset...)
+			// The rules of rnr have already been applied.
+			rules := rnr2.opts.rules
+			rnr2.opts = rnr.opts.merge(rnr2.opts)
+			rnr2.opts.rules = rules
+			return rnr2.Command(args...)
 		}
 	}
+	// Always set, so that commands do not inherit the unset variables of an
+	// enclosing command's Runner.
+	ctx = context.WithValue(ctx, envKey{},
+		envOpts{rnr.opts.unset, rnr.opts.clean})
+	if rnr.opts.term != nil {
+		ctx = context.WithValue(ctx, termKey{}, *rnr.opts.term)
+	}
+	if len(rnr.opts.secrets) > 0 {
+		ctx = context.WithValue(ctx, secretKey{}, rnr.opts.secrets)
+	}
+	if rnr.opts.timeout > 0 {
+		return newTimeoutCmd(ctx, rnr.opts.timeout,
+			func(ctx context.Context) Command {
+				return rnr.Commander.Command(ctx, rnr.env, args...)
+			},
+		)
+	}
 	return rnr.Commander.Command(ctx, rnr.env, args...)
 }
 
 // Run attaches a command to the controlling terminal and executes it.
+//
+// If the command fails, the returned error is an [*Error].
 func (rnr *Runner) Run(args ...string) error {
-	return run(rnr.Command(args...))
+	_, err := rnr.retry(func(n int) (Result, error) {
+		cmd := rnr.Command(args...)
+		s := newSpan(cmd, rnr, args)
+		s.ev.Attempt = n
+		r := Result{Cmd: cmd}
+		if err := run(cmd, s); err != nil {
+			if c, ok := cmd.(Coder); ok {
+				r.Code = c.Code()
+			}
+			return r, &Error{
+				Cmd:     cmdString(cmd),
+				Result:  r,
+				Err:     err,
+				secrets: rnr.opts.secrets,
+			}
+		}
+		return r, nil
+	})
+	return err
 }
 
 // MustRun runs a command and panics on failure.
@@ -115,13 +183,48 @@ func (rnr *Runner) MustRun(args ...string) {
 // command executed successfully. Commands may choose not to implement [Coder],
 // and commands that fail to execute because they cannot be found will have no
 // exit code.
+//
+// If the command fails, the returned error is an [*Error].
 func (rnr *Runner) Get(args ...string) (Result, error) {
-	r, err := get(rnr.Command(args...))
-	if err != nil {
-		err = fmt.Errorf("%w\nout:%slog:%scode: %d",
-			err, fmtout(r.Out), fmtout(r.Log), r.Code)
+	return rnr.retry(func(n int) (Result, error) {
+		cmd := rnr.Command(args...)
+		s := newSpan(cmd, rnr, args)
+		s.ev.Attempt = n
+		r, err := get(cmd, s)
+		if err != nil {
+			return r, rnr.error(cmd, r, err)
+		}
+		return r, nil
+	})
+}
+
+// GetCombined is like [Runner.Get], but it also captures standard output and
+// standard error in [Result.Combined] in the order they were produced.
+//
+// If the command fails, the returned error describes the combined output.
+func (rnr *Runner) GetCombined(args ...string) (Result, error) {
+	return rnr.retry(func(n int) (Result, error) {
+		cmd := rnr.Command(args...)
+		s := newSpan(cmd, rnr, args)
+		s.ev.Attempt = n
+		r, err := capture(cmd, s, new(chunkLog), nil, nil)
+		if err != nil {
+			return r, rnr.error(cmd, r, err)
+		}
+		return r, nil
+	})
+}
+
+// error returns an [*Error] describing a failed command whose output was
+// captured.
+func (rnr *Runner) error(cmd io.Reader, r Result, err error) *Error {
+	return &Error{
+		Cmd:     cmdString(cmd),
+		Result:  r,
+		Err:     err,
+		get:     true,
+		secrets: rnr.opts.secrets,
 	}
-	return r, err
 }
 
 // MustGet runs a command and captures its output in a [Result].
@@ -140,19 +243,65 @@ func (rnr *Runner) Close() error {
 
 // Env returns the value of an environment variable.
 //
-// By default, it parses the output of an env command. [Commander]
-// implementations may customize this behavior by implementing [Enver].
+// Variables set or unset on the Runner are reported without running any
+// commands. Otherwise, by default, it parses the output of an env command.
+// [Commander] implementations may customize this behavior by implementing
+// [Enver].
 func (rnr *Runner) Env(name string) (value string) {
+	if v, ok := rnr.env[name]; ok {
+		return v
+	}
+	if rnr.opts.clean || slices.Contains(rnr.opts.unset, name) {
+		return ""
+	}
 	if enver, ok := rnr.Commander.(Enver); ok {
 		return enver.Env(name)
 	}
-	scanner := bufio.NewScanner(rnr.Command("env"))
-	for scanner.Scan() {
-		line := scanner.Text()
-		k, v, ok := strings.Cut(line, "=")
+	for line, err := range rnr.scan(false, bufio.ScanLines, "env") {
+		if err != nil {
+			break
+		}
+		k, v, ok := strings.Cut(string(line), "=")
 		if ok && k == name {
 			return v
 		}
 	}
 	return
 }
+
+// Environ returns the environment variables of commands run by the Runner.
+//
+// By default, it parses the output of an env command. [Commander]
+// implementations may customize this behavior by implementing [Environer], in
+// which case the variables set or unset on the Runner are applied to the
+// result.
+func (rnr *Runner) Environ() map[string]string {
+	env := make(map[string]string)
+	environer, ok := rnr.Commander.(Environer)
+	if !ok {
+		var last string
+		for line, err := range rnr.scan(false, bufio.ScanLines, "env") {
+			if err != nil {
+				break
+			}
+			k, v, ok := strings.Cut(string(line), "=")
+			if !ok {
+				if last != "" {
+					// Continuation of a multi-line value.
+					env[last] += "\n" + string(line)
+				}
+				continue
+			}
+			env[k], last = v, k
+		}
+		return env
+	}
+	if !rnr.opts.clean {
+		maps.Copy(env, environer.Environ())
+	}
+	for _, k := range rnr.opts.unset {
+		delete(env, k)
+	}
+	maps.Copy(env, rnr.env)
+	return env
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -87,6 +88,34 @@ func (rnrtests) TestEnv(t *testing.T, rnr *cmdio.Runner) {
 	}
 }
 
+func (rnrtests) TestEnvUnset(t *testing.T, rnr *cmdio.Runner) {
+	rnr = rnr.WithEnv(map[string]string{
+		"TEST_ENV": "testenv",
+	}).WithoutEnv("TEST_ENV")
+	r, err := rnr.Get("env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(r.Out, "\n") {
+		if strings.HasPrefix(line, "TEST_ENV=") {
+			t.Errorf("[env] contains %q, want TEST_ENV unset", line)
+		}
+	}
+}
+
+func (rnrtests) TestEnvClean(t *testing.T, rnr *cmdio.Runner) {
+	rnr = rnr.WithCleanEnv().WithEnv(map[string]string{
+		"TEST_ENV": "testenv",
+	})
+	r, err := rnr.Get("env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Out, "TEST_ENV=testenv"; got != want {
+		t.Errorf("[env] = %q, want %q", got, want)
+	}
+}
+
 func (rnrtests) TestContext(t *testing.T, rnr *cmdio.Runner) {
 	ctx, cancel := context.WithCancel(context.Background())
 	rnr = rnr.WithContext(ctx)
@@ -104,6 +133,22 @@ func (rnrtests) TestContext(t *testing.T, rnr *cmdio.Runner) {
 	<-ch
 }
 
+func (rnrtests) TestTimeout(t *testing.T, rnr *cmdio.Runner) {
+	start := time.Now()
+	_, err := rnr.WithTimeout(100*time.Millisecond).Get("sleep", "5")
+	if err == nil {
+		t.Error("Get(sleep 5) err = <nil>, want cmdio.ErrTimeout")
+	} else if !errors.Is(err, cmdio.ErrTimeout) {
+		t.Errorf("Get(sleep 5) err = %q, want cmdio.ErrTimeout", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Get(sleep 5) took %v, want timeout", d)
+	}
+	if _, err := rnr.WithTimeout(5 * time.Second).Get("true"); err != nil {
+		t.Errorf("Get(true) err = %q, want <nil>", err)
+	}
+}
+
 func (rnrtests) TestPwd(t *testing.T, rnr *cmdio.Runner) {
 	rnr = rnr.WithEnv(map[string]string{"PWD": "/tmp"})
 	r, err := rnr.Get("pwd")
@@ -115,6 +160,34 @@ func (rnrtests) TestPwd(t *testing.T, rnr *cmdio.Runner) {
 	}
 }
 
+func (rnrtests) TestEnviron(t *testing.T, rnr *cmdio.Runner) {
+	if rnr.Environ()["HOME"] == "" {
+		t.Errorf("Environ()[HOME] = <empty>, want HOME")
+	}
+	if rnr.Env("HOME") == "" {
+		t.Errorf("Env(HOME) = <empty>, want HOME")
+	}
+	rnr = rnr.WithEnv(map[string]string{"TEST_ENV": "testenv"}).
+		WithoutEnv("HOME")
+	env := rnr.Environ()
+	if got, want := env["TEST_ENV"], "testenv"; got != want {
+		t.Errorf("Environ()[TEST_ENV] = %q, want %q", got, want)
+	}
+	if v, ok := env["HOME"]; ok {
+		t.Errorf("Environ()[HOME] = %q, want unset", v)
+	}
+	if env["PATH"] == "" {
+		t.Errorf("Environ()[PATH] = <empty>, want PATH")
+	}
+	if got, want := rnr.Env("PATH"), env["PATH"]; got != want {
+		t.Errorf("Env(PATH) = %q, want %q", got, want)
+	}
+}
+
+func (rnrtests) TestCopy(t *testing.T, rnr *cmdio.Runner) {
+	testCopy(t, rnr)
+}
+
 func mustv[T any](v T, err error) T {
 	if err != nil {
 		panic(err)
@@ -6,8 +6,11 @@ import (
 	"log"
 	"os"
 	"strings"
+	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/cmdiotest"
 	"lesiw.io/cmdio/sub"
 	"lesiw.io/cmdio/sys"
 	"lesiw.io/prefix"
@@ -236,3 +239,41 @@ func ExampleRunner_WithCommand_env() {
 	// Output:
 	// hello from cmdio
 }
+
+func TestEnvironFallback(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	cdr.Handle([]string{"env"}, cmdiotest.Response{
+		Out: "HOME=/root\nMULTI=one\ntwo\nPATH=/bin\n",
+	})
+
+	got := rnr.Environ()
+
+	want := map[string]string{
+		"HOME":  "/root",
+		"MULTI": "one\ntwo",
+		"PATH":  "/bin",
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Environ() -want +got\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr, cdr := cmdiotest.New()
+	rnr = rnr.WithEnv(map[string]string{"A": "1"}).WithoutEnv("B")
+
+	if got, want := rnr.Env("A"), "1"; got != want {
+		t.Errorf("Env(A) = %q, want %q", got, want)
+	}
+	if got, want := rnr.Env("B"), ""; got != want {
+		t.Errorf("Env(B) = %q, want %q", got, want)
+	}
+	if got, want := rnr.WithCleanEnv().Env("C"), ""; got != want {
+		t.Errorf("Env(C) = %q, want %q", got, want)
+	}
+	if calls := cdr.Calls(); len(calls) > 0 {
+		t.Errorf("Calls() = %v, want none", calls)
+	}
+}
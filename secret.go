@@ -0,0 +1,115 @@
+package cmdio
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// mask replaces secret values.
+const mask = "***"
+
+type secretKey struct{}
+
+// WithSecret creates a new Runner that treats each of values as secret.
+// The new Runner will otherwise be identical to its parent.
+//
+// Secret values are masked in traces and errors, and [Commander]
+// implementations mask them in the String forms of their commands.
+func (rnr *Runner) WithSecret(values ...string) *Runner {
+	rnr2 := rnr.clone()
+	rnr2.opts.secrets = append(slices.Clip(rnr.opts.secrets), values...)
+	return rnr2
+}
+
+// WithSecretEnv is like [Runner.WithEnv], but it also treats the values of env
+// as secret.
+func (rnr *Runner) WithSecretEnv(env map[string]string) *Runner {
+	var values []string
+	for _, v := range env {
+		if v != Unset {
+			values = append(values, v)
+		}
+	}
+	return rnr.WithEnv(env).WithSecret(values...)
+}
+
+// Redact masks the secret values of the Runner that instantiated a command
+// with the given context.
+//
+// [Commander] implementations should use Redact in the String methods of
+// their commands.
+func Redact(ctx context.Context, s string) string {
+	secrets, _ := ctx.Value(secretKey{}).([]string)
+	return redact(secrets, s)
+}
+
+// pipeSecrets returns the secret values of the Runners that instantiated the
+// stages of a pipeline.
+func pipeSecrets(src io.Reader, cmd []io.ReadWriter) []string {
+	var secrets []string
+	for i := 0; i <= len(cmd); i++ {
+		switch c := stage(src, cmd, i).(type) {
+		case Contexter:
+			s, _ := c.Context().Value(secretKey{}).([]string)
+			secrets = append(secrets, s...)
+		case rejectedCmd:
+			secrets = append(secrets, c.secrets...)
+		}
+	}
+	return secrets
+}
+
+func redact(secrets []string, s string) string {
+	if len(secrets) == 0 {
+		return s
+	}
+	// Mask secrets as they would appear quoted for a shell, too.
+	var olds []string
+	for _, v := range secrets {
+		if v == "" {
+			continue
+		}
+		olds = append(olds, v)
+		if q := strings.ReplaceAll(v, `'`, `'\''`); q != v {
+			olds = append(olds, q)
+		}
+	}
+	// Replace longer secrets first, in case secrets overlap.
+	slices.SortFunc(olds, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	for _, v := range olds {
+		s = strings.ReplaceAll(s, v, mask)
+	}
+	return s
+}
+
+// redactMap returns a copy of m with its values redacted.
+func redactMap(secrets []string, m map[string]string) map[string]string {
+	if len(secrets) == 0 || m == nil {
+		return m
+	}
+	m = maps.Clone(m)
+	for k, v := range m {
+		m[k] = redact(secrets, v)
+	}
+	return m
+}
+
+// redactedError is an error whose message has its secrets redacted.
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+func (e redactedError) Error() string {
+	return redact(e.secrets, e.err.Error())
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
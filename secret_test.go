@@ -0,0 +1,116 @@
+package cmdio_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sub"
+	"lesiw.io/cmdio/sys"
+)
+
+func ExampleRunner_WithSecret() {
+	rnr := sys.Runner().WithSecret("hunter2")
+	fmt.Println(rnr.Command("login", "--password", "hunter2"))
+	// Output:
+	// login --password ***
+}
+
+func TestSecretString(t *testing.T) {
+	tests := []struct {
+		name string
+		rnr  *cmdio.Runner
+		args []string
+		want string
+	}{{
+		name: "sys env",
+		rnr: sys.Runner().WithSecretEnv(map[string]string{
+			"TOKEN": "hunter2",
+		}),
+		args: []string{"env"},
+		want: "TOKEN=*** env",
+	}, {
+		name: "sys quoted",
+		rnr:  sys.Runner().WithSecret("it's secret"),
+		args: []string{"echo", "it's secret"},
+		want: "echo '***'",
+	}, {
+		name: "sub",
+		rnr:  sub.New("curl", "-u").WithSecret("user:hunter2"),
+		args: []string{"user:hunter2"},
+		want: "curl -u ***",
+	}, {
+		name: "rerouted",
+		rnr: sys.Runner().WithSecret("hunter2").
+			WithCommand("curl", sub.New("curl", "-s")),
+		args: []string{"curl", "hunter2"},
+		want: "curl -s curl ***",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fmt.Sprint(tt.rnr.Command(tt.args...))
+			if got != tt.want {
+				t.Errorf("Command(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretTrace(t *testing.T) {
+	var trc, jsn bytes.Buffer
+	swap[cmdio.Tracer](t, &cmdio.TraceHook, cmdio.MultiTracer(
+		cmdio.TextTracer{W: &trc},
+		cmdio.NewJSONTracer(&jsn),
+	))
+	rnr := sys.Runner().WithSecretEnv(map[string]string{"TOKEN": "hunter2"})
+
+	_, _ = rnr.Get("sh", "-c", `echo hunter2; exit 1`)
+
+	for name, out := range map[string]string{
+		"text": trc.String(),
+		"json": jsn.String(),
+	} {
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("%s trace = %q, want secret masked", name, out)
+		}
+	}
+}
+
+func TestSecretError(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := sys.Runner().WithSecret("hunter2")
+
+	r, err := rnr.Get("sh", "-c", `echo hunter2; echo hunter2 >&2; exit 1`)
+
+	if err == nil {
+		t.Fatal("rnr.Get() err = <nil>, want error")
+	}
+	if msg := err.Error(); strings.Contains(msg, "hunter2") {
+		t.Errorf("rnr.Get() err = %q, want secret masked", msg)
+	}
+	if got, want := r.Out, "hunter2"; got != want {
+		t.Errorf("rnr.Get().Out = %q, want %q", got, want)
+	}
+}
+
+func TestSecretPipeError(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := sys.Runner().WithSecret("hunter2")
+
+	_, err := cmdio.GetPipe(
+		rnr.Command("echo", "hunter2"),
+		rnr.Command("sh", "-c", "cat; echo hunter2 >&2; exit 1", "hunter2"),
+	)
+
+	var e *cmdio.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("GetPipe() err = %v, want *cmdio.Error", err)
+	}
+	if msg := err.Error(); strings.Contains(msg, "hunter2") {
+		t.Errorf("GetPipe() err = %q, want secret masked", msg)
+	}
+}
@@ -0,0 +1,200 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/sh"
+)
+
+var errInput = errors.New("session: commands do not read standard input")
+
+type cmd struct {
+	cmdio.Command
+
+	cdr  *cdr
+	ctx  context.Context
+	env  map[string]string
+	args []string
+	code int
+
+	start   func() error
+	release func()
+	stop    func() bool
+	mark    []byte
+	logdone <-chan struct{}
+	err     error // Set once the command has finished.
+	done    bool
+
+	attached bool
+	logger   io.Writer
+
+	mu sync.Mutex // Held while reading.
+}
+
+func newCmd(
+	cdr *cdr, ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	c := &cmd{
+		cdr:  cdr,
+		ctx:  ctx,
+		env:  env,
+		args: args,
+	}
+	c.start = sync.OnceValue(c.startFunc)
+	c.release = sync.OnceFunc(func() { <-c.cdr.sem })
+	return c
+}
+
+func (c *cmd) startFunc() error {
+	if len(c.args) == 0 {
+		return fmt.Errorf("no command")
+	}
+	select {
+	case c.cdr.sem <- struct{}{}:
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+	if err := c.cdr.start(); err != nil {
+		c.release()
+		return err
+	}
+	if err := c.cdr.failed(); err != nil {
+		c.release()
+		return err
+	}
+	mark := newMark()
+	c.mark = []byte(mark)
+	logger := c.logger
+	if c.attached {
+		logger = os.Stderr
+	} else if logger == nil {
+		logger = io.Discard
+	}
+	c.logdone = c.cdr.log.begin(logger, c.mark)
+	c.stop = context.AfterFunc(c.ctx, func() {
+		c.cdr.fail(fmt.Errorf("%w: %w", ErrClosed, context.Cause(c.ctx)))
+	})
+	s := script(c.ctx, c.env, c.args, mark)
+	if _, err := io.WriteString(c.cdr.sh, s); err != nil {
+		c.cdr.fail(fmt.Errorf("%w: %w", ErrClosed, err))
+		c.finish(err)
+		return err
+	}
+	return nil
+}
+
+func (c *cmd) Attach() error {
+	c.attached = true
+	return nil
+}
+
+func (c *cmd) Log(w io.Writer) {
+	c.logger = w
+}
+
+func (c *cmd) Write([]byte) (int, error) {
+	return 0, errInput
+}
+
+// Close discards the rest of the output of a command that has started,
+// waiting for it to finish, so that the next command can start.
+func (c *cmd) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mark == nil || c.done {
+		return nil
+	}
+	_, err := io.Copy(io.Discard, readerFunc(c.read))
+	if _, ok := err.(exitError); ok {
+		return nil // The exit code is reported by Code.
+	}
+	return err
+}
+
+func (c *cmd) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	if c.attached {
+		if _, err := io.Copy(os.Stdout, readerFunc(c.read)); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	return c.read(p)
+}
+
+func (c *cmd) read(p []byte) (int, error) {
+	if c.done {
+		if c.err != nil {
+			return 0, c.err
+		}
+		return 0, io.EOF
+	}
+	n, code, err := c.cdr.read(p, c.mark)
+	if err == io.EOF {
+		// Wait for the rest of standard error.
+		select {
+		case <-c.logdone:
+		case <-c.ctx.Done():
+			err = c.ctx.Err()
+		}
+		c.code = code
+		if err == io.EOF && code != 0 {
+			err = exitError(code)
+		}
+	} else if err != nil {
+		if cerr := c.cdr.failed(); cerr != nil {
+			err = cerr
+		}
+		c.cdr.fail(err)
+	}
+	if err != nil {
+		c.finish(err)
+		if err == io.EOF {
+			return n, err
+		}
+		return n, c.err
+	}
+	return n, nil
+}
+
+// finish records the outcome of the command and lets the next one start.
+func (c *cmd) finish(err error) {
+	if err != io.EOF {
+		c.err = err
+	}
+	c.done = true
+	c.stop()
+	c.release()
+}
+
+func (c *cmd) Code() int {
+	return c.code
+}
+
+func (c *cmd) Context() context.Context {
+	return c.ctx
+}
+
+func (c *cmd) String() string {
+	return cmdio.Redact(c.ctx, sh.String(c.ctx, c.env, c.args))
+}
+
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,270 @@
+// Package session provides a [cmdio.Commander] that runs commands in a single
+// long-running shell, so that changes to the shell's state, such as its
+// working directory, variables, and functions, persist between commands.
+//
+// Each command is written to the shell as a line of script, and its output
+// is delimited by markers that the shell prints when the command finishes.
+// Running many commands this way avoids the cost of starting a process for
+// each of them, which is significant for Runners such as ctr.
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/sh"
+)
+
+// ErrClosed is returned by commands run in a session whose shell has exited.
+var ErrClosed = errors.New("session closed")
+
+type cdr struct {
+	rnr   *cmdio.Runner
+	shell []string
+
+	sem   chan struct{} // Held by the running command.
+	start func() error
+	log   logDemux
+	out   []byte // Output read from the shell but not yet consumed.
+	buf   []byte
+
+	mu     sync.Mutex
+	sh     io.ReadWriter
+	cancel context.CancelFunc
+	err    error // Why the shell exited, if it has.
+}
+
+// New instantiates a [cmdio.Runner] that runs commands in a shell started by
+// rnr. The shell is started when the first command is executed, and stopped
+// when the returned Runner is closed.
+//
+// By default, the shell is sh. Another POSIX shell may be given as shell,
+// along with its arguments.
+//
+// Commands run one at a time, in the order they are executed. Their standard
+// input is empty, and writing to them fails. Variables set or unset with
+// [cmdio.Runner.WithEnv] apply to each command as they would in a shell
+// script; a PWD runs the command in that directory, then returns to the
+// shell's previous working directory. Commands with unset variables, or run
+// in a clean environment, run in a subshell, so changes they make to the
+// shell's state do not persist. If a command's context is done before it
+// finishes, the shell is killed, and later commands fail with [ErrClosed].
+//
+// A command holds the shell until its output has been read to the end or it
+// is closed, which discards the rest of its output.
+func New(rnr *cmdio.Runner, shell ...string) *cmdio.Runner {
+	if len(shell) == 0 {
+		shell = []string{"sh"}
+	}
+	c := &cdr{rnr: rnr, shell: shell, sem: make(chan struct{}, 1)}
+	c.start = sync.OnceValue(c.startFunc)
+	return new(cmdio.Runner).
+		WithContext(context.Background()).
+		WithCommander(c)
+}
+
+func (c *cdr) Command(
+	ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	return newCmd(c, ctx, env, args...)
+}
+
+func (c *cdr) startFunc() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	shell := c.rnr.WithContext(ctx).Command(c.shell...)
+	l, ok := shell.(cmdio.Logger)
+	if !ok {
+		cancel()
+		return fmt.Errorf("session: %v does not implement cmdio.Logger",
+			shell)
+	}
+	l.Log(&c.log)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sh, c.cancel = shell, cancel
+	return nil
+}
+
+// fail kills the shell, if it is running, and records why.
+func (c *cdr) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+func (c *cdr) failed() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close stops the shell. It must not be called while commands are running.
+func (c *cdr) Close() error {
+	c.mu.Lock()
+	shell, err := c.sh, c.err
+	if err == nil {
+		c.err = ErrClosed
+	}
+	c.mu.Unlock()
+	if shell == nil || err != nil {
+		return nil
+	}
+	defer c.cancel()
+	if _, err := io.WriteString(shell, "exit 0\n"); err != nil {
+		return err
+	}
+	if closer, ok := shell.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(io.Discard, shell)
+	return err
+}
+
+// read reads the output of the running command, which ends at mark. Once
+// mark is read, read returns the exit code printed after it and io.EOF.
+func (c *cdr) read(p []byte, mark []byte) (n, code int, err error) {
+	for {
+		if i := bytes.Index(c.out, mark); i > 0 {
+			n = copy(p, c.out[:i])
+			c.out = c.out[n:]
+			return n, 0, nil
+		} else if i == 0 {
+			if j := bytes.IndexByte(c.out, '\n'); j >= 0 {
+				s := strings.TrimSpace(string(c.out[len(mark):j]))
+				c.out = c.out[j+1:]
+				if _, err := fmt.Sscan(s, &code); err != nil {
+					return 0, 0, fmt.Errorf("bad exit status %q: %w", s, err)
+				}
+				return 0, code, io.EOF
+			}
+		} else if safe := len(c.out) - len(mark) + 1; safe > 0 {
+			// Hold back what may be the start of mark.
+			n = copy(p, c.out[:safe])
+			c.out = c.out[n:]
+			return n, 0, nil
+		}
+		if c.buf == nil {
+			c.buf = make([]byte, 32*1024)
+		}
+		m, err := c.sh.Read(c.buf)
+		c.out = append(c.out, c.buf[:m]...)
+		if err != nil && m == 0 {
+			if err == io.EOF {
+				err = ErrClosed
+			}
+			return 0, 0, err
+		}
+	}
+}
+
+// script returns the line of script that runs a command, followed by mark
+// and its exit code on standard output, then mark on standard error.
+func script(
+	ctx context.Context, env map[string]string, args []string, mark string,
+) string {
+	var b strings.Builder
+	set, unset, clean := cmdio.ParseEnv(ctx, env)
+	dir, chdir := set["PWD"]
+	delete(set, "PWD")
+	if chdir {
+		b.WriteString("cmdio_pwd=$PWD; { cd -- " + sh.Quote(dir) + " && ")
+	} else {
+		b.WriteString("{ ")
+	}
+	// Unset variables in a subshell, rather than with env, so that builtins
+	// and functions can still be run.
+	subshell := clean || len(unset) > 0
+	if subshell {
+		b.WriteString("( unset -v")
+		if clean {
+			b.WriteString(" " + exported)
+		}
+		for _, k := range unset {
+			b.WriteString(" " + sh.Quote(k))
+		}
+		b.WriteString("; ")
+		for _, k := range sh.SortKeys(set) {
+			b.WriteString("export " + k + "=" + sh.Quote(set[k]) + "; ")
+		}
+	} else {
+		for _, k := range sh.SortKeys(set) {
+			b.WriteString(k + "=" + sh.Quote(set[k]) + " ")
+		}
+	}
+	b.WriteString(sh.Join(args))
+	if subshell {
+		b.WriteString(" )")
+	}
+	fmt.Fprintf(&b, "; } </dev/null; printf '%%s %%d\\n' %s \"$?\"; ", mark)
+	if chdir {
+		b.WriteString(`cd -- "$cmdio_pwd"; `)
+	}
+	fmt.Fprintf(&b, "printf '%%s\\n' %s >&2\n", mark)
+	return b.String()
+}
+
+// exported expands to the names of the variables exported by the shell.
+const exported = `$(env | sed -n 's/^\([A-Za-z_][A-Za-z0-9_]*\)=.*/\1/p')`
+
+func newMark() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "cmdio-" + hex.EncodeToString(b)
+}
+
+// A logDemux routes the standard error of the shell to the logger of the
+// running command.
+type logDemux struct {
+	mu   sync.Mutex
+	w    io.Writer
+	mark []byte
+	buf  []byte
+	done chan struct{}
+}
+
+// begin routes standard error to w until mark is written. The returned
+// channel is closed once it has been.
+func (d *logDemux) begin(w io.Writer, mark []byte) <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w, d.mark, d.buf = w, mark, nil
+	d.done = make(chan struct{})
+	return d.done
+}
+
+func (d *logDemux) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mark == nil {
+		return len(p), nil // No command is running.
+	}
+	d.buf = append(d.buf, p...)
+	if i := bytes.Index(d.buf, d.mark); i >= 0 {
+		_, _ = d.w.Write(d.buf[:i])
+		d.buf = d.buf[i:]
+		if bytes.IndexByte(d.buf, '\n') >= 0 {
+			d.mark, d.buf = nil, nil
+			close(d.done)
+		}
+	} else if safe := len(d.buf) - len(d.mark) + 1; safe > 0 {
+		// Hold back what may be the start of mark.
+		_, _ = d.w.Write(d.buf[:safe])
+		d.buf = d.buf[safe:]
+	}
+	return len(p), nil
+}
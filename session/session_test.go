@@ -0,0 +1,270 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sub"
+	"lesiw.io/cmdio/sys"
+)
+
+func newSession(t *testing.T) *cmdio.Runner {
+	t.Helper()
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(sys.Runner())
+	t.Cleanup(func() {
+		if err := rnr.Close(); err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	})
+	return rnr
+}
+
+func TestState(t *testing.T) {
+	rnr := newSession(t)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "activate")
+	err = os.WriteFile(script, []byte("GREETING=hello\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rnr.Run("cd", dir); err != nil {
+		t.Fatalf("Run(cd) = %q", err)
+	}
+	if err := rnr.Run("export", "FOO=bar"); err != nil {
+		t.Fatalf("Run(export) = %q", err)
+	}
+	if err := rnr.Run(".", "./activate"); err != nil {
+		t.Fatalf("Run(.) = %q", err)
+	}
+
+	if r, err := rnr.Get("pwd"); err != nil || r.Out != dir {
+		t.Errorf("Get(pwd) = %q, %v, want %q", r.Out, err, dir)
+	}
+	if r, err := rnr.Get("printenv", "FOO"); err != nil || r.Out != "bar" {
+		t.Errorf("Get(printenv FOO) = %q, %v, want %q", r.Out, err, "bar")
+	}
+	r, err := rnr.Get("eval", `printf %s "$GREETING"`)
+	if err != nil || r.Out != "hello" {
+		t.Errorf("Get(eval) = %q, %v, want %q", r.Out, err, "hello")
+	}
+}
+
+func TestResult(t *testing.T) {
+	rnr := newSession(t)
+
+	r, err := rnr.Get("sh", "-c", "echo out; echo err >&2; exit 3")
+
+	if err == nil {
+		t.Errorf("Get() = <nil>, want error")
+	}
+	if got, want := r.Out, "out"; got != want {
+		t.Errorf("Get().Out = %q, want %q", got, want)
+	}
+	if got, want := r.Log, "err"; got != want {
+		t.Errorf("Get().Log = %q, want %q", got, want)
+	}
+	if got, want := r.Code, 3; got != want {
+		t.Errorf("Get().Code = %d, want %d", got, want)
+	}
+	if _, err := rnr.Get("true"); err != nil {
+		t.Errorf("Get(true) = %q, want <nil>", err)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	rnr := newSession(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"printf", "no newline"}, "no newline"},
+		{[]string{"printf", "%s", "cmdio-"}, "cmdio-"},
+		{[]string{"printf", "a\n\nb"}, "a\n\nb"},
+		{[]string{"echo", "it's $HOME"}, "it's $HOME\n"},
+		{
+			[]string{"head", "-c", "100000", "/dev/zero"},
+			strings.Repeat("\x00", 1e5),
+		},
+	}
+	for _, tt := range tests {
+		out, err := io.ReadAll(rnr.Command(tt.args...))
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+		}
+		if got := string(out); got != tt.want {
+			t.Errorf("%q = %.40q (%d bytes), want %.40q (%d bytes)",
+				tt.args, got, len(got), tt.want, len(tt.want))
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	rnr := newSession(t)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := rnr.Get("pwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rnr.WithEnv(map[string]string{"PWD": dir, "X": "a b"}).
+		Get("sh", "-c", `pwd; printf %s "$X"`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Out, dir+"\na b"; got != want {
+		t.Errorf("Get() = %q, want %q", got, want)
+	}
+	if r, err := rnr.Get("pwd"); err != nil || r.Out != before.Out {
+		t.Errorf("Get(pwd) = %q, %v, want %q", r.Out, err, before.Out)
+	}
+	r, err = rnr.WithEnv(map[string]string{"HOME": cmdio.Unset}).
+		Get("sh", "-c", `printf %s "${HOME-unset}"`)
+	if err != nil || r.Out != "unset" {
+		t.Errorf("Get(unset HOME) = %q, %v, want %q", r.Out, err, "unset")
+	}
+	if _, err := rnr.WithoutEnv("HOME").Get("cd", "/"); err != nil {
+		t.Errorf("Get(cd) with HOME unset = %v, want <nil>", err)
+	}
+	r, err = rnr.WithCleanEnv().WithEnv(map[string]string{"X": "x"}).
+		Get("eval", `printf %s "${HOME-unset} $X"`)
+	if err != nil || r.Out != "unset x" {
+		t.Errorf("Get(eval) in clean env = %q, %v, want %q",
+			r.Out, err, "unset x")
+	}
+}
+
+func TestSub(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(sub.WithRunner(sys.Runner(), "env", "CMDIO_SUB=1"))
+	defer rnr.Close()
+
+	if err := rnr.Run("export", "FOO=bar"); err != nil {
+		t.Fatalf("Run(export) = %q", err)
+	}
+	r, err := rnr.Get("sh", "-c", `printf %s "$CMDIO_SUB $FOO"`)
+
+	if err != nil || r.Out != "1 bar" {
+		t.Errorf("Get() = %q, %v, want %q", r.Out, err, "1 bar")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	rnr := newSession(t)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			want := fmt.Sprint(i)
+			r, err := rnr.Get("echo", want)
+			if err != nil || r.Out != want {
+				t.Errorf("Get(echo %s) = %q, %v", want, r.Out, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestInput(t *testing.T) {
+	rnr := newSession(t)
+
+	_, err := cmdio.GetPipe(strings.NewReader("hi"), rnr.Command("cat"))
+
+	if !errors.Is(err, errInput) {
+		t.Errorf("GetPipe() = %v, want errInput", err)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	rnr := newSession(t)
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 100*time.Millisecond,
+	)
+	defer cancel()
+
+	start := time.Now()
+	_, err := rnr.WithContext(ctx).Get("sleep", "5")
+
+	if err == nil {
+		t.Errorf("Get(sleep) = <nil>, want error")
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Get(sleep) took %v, want cancellation", d)
+	}
+	if _, err := rnr.Get("true"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Get(true) = %v, want ErrClosed", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	rnr := newSession(t)
+	cmd := rnr.Command("seq", "100000")
+	if _, err := cmd.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.(io.Closer).Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+
+	checkNext(t, rnr)
+}
+
+func TestPipeClosed(t *testing.T) {
+	rnr := newSession(t)
+
+	_, _ = cmdio.GetPipe(
+		rnr.Command("seq", "100000"),
+		sys.Runner().Command("head", "-1"),
+	)
+
+	checkNext(t, rnr)
+}
+
+// checkNext checks that the next command run in a session can start.
+func checkNext(t *testing.T, rnr *cmdio.Runner) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := rnr.WithContext(ctx).Get("echo", "hi")
+	if err != nil || r.Out != "hi" {
+		t.Errorf("Get(echo hi) = %q, %v, want %q", r.Out, err, "hi")
+	}
+}
+
+func TestString(t *testing.T) {
+	rnr := New(sys.Runner()).
+		WithEnv(map[string]string{"PWD": "/tmp", "A": "1"})
+
+	got := fmt.Sprint(rnr.Command("echo", "hello world"))
+
+	if want := "A=1 PWD=/tmp echo 'hello world'"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
@@ -0,0 +1,165 @@
+// Package span records commands as OpenTelemetry spans.
+//
+// A [Tracer] is installed as [cmdio.TraceHook], alongside any other tracers:
+//
+//	cmdio.TraceHook = cmdio.MultiTracer(cmdio.TraceHook, span.New(tp))
+//
+// Each command run by a [cmdio.Runner] becomes a span whose parent is taken
+// from the Runner's context. Each pipeline becomes a span with a child span
+// for each of its stages.
+package span
+
+import (
+	"context"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"lesiw.io/cmdio"
+)
+
+// Attribute keys set on spans.
+const (
+	CommandKey     = attribute.Key("cmdio.command")
+	ArgvKey        = attribute.Key("cmdio.argv")
+	RunnerKey      = attribute.Key("cmdio.runner")
+	AttemptKey     = attribute.Key("cmdio.attempt")
+	ExitCodeKey    = attribute.Key("cmdio.exit_code")
+	BytesInKey     = attribute.Key("cmdio.bytes_in")
+	BytesOutKey    = attribute.Key("cmdio.bytes_out")
+	StageKey       = attribute.Key("cmdio.pipeline.stage")
+	FailedStageKey = attribute.Key("cmdio.pipeline.failed_stage")
+)
+
+// A Tracer is a [cmdio.Tracer] that records commands as spans.
+type Tracer struct {
+	tracer trace.Tracer
+
+	mu    sync.Mutex
+	spans map[uint64]trace.Span
+	pipes map[uint64]*pipeline
+}
+
+type pipeline struct {
+	ctx    context.Context
+	span   trace.Span
+	left   int // Stages that have not yet ended.
+	failed int // Index of the rightmost failed stage, or -1.
+}
+
+// New returns a [Tracer] that creates spans with the given provider.
+func New(tp trace.TracerProvider) *Tracer {
+	return &Tracer{
+		tracer: tp.Tracer("lesiw.io/cmdio"),
+		spans:  make(map[uint64]trace.Span),
+		pipes:  make(map[uint64]*pipeline),
+	}
+}
+
+func (t *Tracer) Trace(e cmdio.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e.Kind {
+	case cmdio.Start:
+		t.start(e)
+	case cmdio.End:
+		t.end(e)
+	}
+}
+
+func (t *Tracer) start(e cmdio.Event) {
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attrs := []attribute.KeyValue{CommandKey.String(e.Cmd)}
+	if e.Args != nil {
+		attrs = append(attrs, ArgvKey.StringSlice(e.Args))
+	}
+	if e.Commander != nil {
+		attrs = append(attrs, RunnerKey.String(runnerType(e.Commander)))
+	}
+	if e.Attempt > 0 {
+		attrs = append(attrs, AttemptKey.Int(e.Attempt))
+	}
+	if e.Pipeline != nil {
+		p, ok := t.pipes[e.PipelineID]
+		if !ok {
+			p = &pipeline{left: len(e.Pipeline), failed: -1}
+			p.ctx, p.span = t.tracer.Start(ctx,
+				strings.Join(e.Pipeline, " | "),
+				trace.WithTimestamp(e.Time),
+			)
+			t.pipes[e.PipelineID] = p
+		}
+		ctx = p.ctx
+		attrs = append(attrs, StageKey.Int(e.Stage))
+	}
+	_, t.spans[e.ID] = t.tracer.Start(ctx, spanName(e),
+		trace.WithTimestamp(e.Time),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func (t *Tracer) end(e cmdio.Event) {
+	s, ok := t.spans[e.ID]
+	if !ok {
+		return
+	}
+	delete(t.spans, e.ID)
+	s.SetAttributes(
+		ExitCodeKey.Int(e.Code),
+		BytesInKey.Int64(e.BytesIn),
+		BytesOutKey.Int64(e.BytesOut),
+	)
+	if e.Err != nil {
+		s.RecordError(e.Err)
+		s.SetStatus(codes.Error, e.Err.Error())
+	}
+	s.End(trace.WithTimestamp(e.Time))
+
+	if e.Pipeline == nil {
+		return
+	}
+	p, ok := t.pipes[e.PipelineID]
+	if !ok {
+		return
+	}
+	if e.Err != nil && e.Stage > p.failed {
+		p.failed = e.Stage
+	}
+	if p.left--; p.left > 0 {
+		return
+	}
+	delete(t.pipes, e.PipelineID)
+	if p.failed >= 0 {
+		p.span.SetAttributes(FailedStageKey.Int(p.failed))
+		p.span.SetStatus(codes.Error, e.Pipeline[p.failed]+" failed")
+	}
+	p.span.End(trace.WithTimestamp(e.Time))
+}
+
+// spanName returns the name of the command's program, if known.
+func spanName(e cmdio.Event) string {
+	if len(e.Args) > 0 {
+		return e.Args[0]
+	}
+	return e.Cmd
+}
+
+// runnerType names the kind of Runner that uses cdr after the package that
+// implements it, such as sys or ctr.
+func runnerType(cdr cmdio.Commander) string {
+	t := reflect.TypeOf(cdr)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+	return path.Base(t.PkgPath())
+}
@@ -0,0 +1,116 @@
+package span
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+func setup(
+	t *testing.T,
+) (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	swap(t, &cmdio.Trace, io.Discard)
+	swap[cmdio.Tracer](t, &cmdio.TraceHook, New(tp))
+	return exp, tp
+}
+
+func TestCommandSpan(t *testing.T) {
+	exp, tp := setup(t)
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "build")
+	rnr := sys.Runner().WithContext(ctx)
+
+	_, _ = rnr.Get("sh", "-c", "echo hi; exit 2")
+	parent.End()
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("got %d spans, want %d", got, want)
+	}
+	s := spans[0]
+	if got, want := s.Name, "sh"; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	want := parent.SpanContext().SpanID()
+	if got := s.Parent.SpanID(); got != want {
+		t.Errorf("span parent = %v, want %v", got, want)
+	}
+	attrs := attrMap(s.Attributes)
+	if got, want := attrs[RunnerKey].AsString(), "sys"; got != want {
+		t.Errorf("%s = %q, want %q", RunnerKey, got, want)
+	}
+	if got, want := attrs[ExitCodeKey].AsInt64(), int64(2); got != want {
+		t.Errorf("%s = %d, want %d", ExitCodeKey, got, want)
+	}
+	argv := attrs[ArgvKey].AsStringSlice()
+	if got, want := len(argv), 3; got != want {
+		t.Errorf("len(%s) = %d, want %d", ArgvKey, got, want)
+	}
+	if got, want := s.Status.Code, codes.Error; got != want {
+		t.Errorf("span status = %v, want %v", got, want)
+	}
+	if !s.EndTime.After(s.StartTime) {
+		t.Errorf("span duration = %v, want > 0", s.EndTime.Sub(s.StartTime))
+	}
+}
+
+func TestPipelineSpans(t *testing.T) {
+	exp, tp := setup(t)
+	ctx, root := tp.Tracer("test").Start(context.Background(), "build")
+	rnr := sys.Runner().WithContext(ctx)
+
+	_ = cmdio.Pipe(
+		strings.NewReader("hello\n"),
+		rnr.Command("sh", "-c", "cat; exit 1"),
+		rnr.Command("cat"),
+	)
+	root.End()
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 5; got != want {
+		t.Fatalf("got %d spans, want %d", got, want)
+	}
+	parent := spans[3]
+	want := parent.SpanContext.SpanID()
+	for i, s := range spans[:3] {
+		if got := s.Parent.SpanID(); got != want {
+			t.Errorf("spans[%d] parent = %v, want %v", i, got, want)
+		}
+	}
+	want = root.SpanContext().SpanID()
+	if got := parent.Parent.SpanID(); got != want {
+		t.Errorf("pipeline parent = %v, want %v", got, want)
+	}
+	attrs := attrMap(parent.Attributes)
+	if got, want := attrs[FailedStageKey].AsInt64(), int64(1); got != want {
+		t.Errorf("%s = %d, want %d", FailedStageKey, got, want)
+	}
+	if got, want := parent.Status.Code, codes.Error; got != want {
+		t.Errorf("pipeline status = %v, want %v", got, want)
+	}
+}
+
+func attrMap(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
@@ -0,0 +1,267 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/sh"
+)
+
+type cmd struct {
+	cmdio.Command
+
+	cdr  *cdr
+	ctx  context.Context
+	env  map[string]string
+	args []string
+	code int
+
+	sess    *ssh.Session
+	attach  bool
+	cmdwait chan error
+
+	start func() error
+	wait  func() error
+
+	reader io.Reader
+	writer io.WriteCloser
+	logger io.Writer
+}
+
+func newCmd(
+	cdr *cdr, ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	c := &cmd{
+		cdr:     cdr,
+		ctx:     ctx,
+		env:     env,
+		args:    args,
+		cmdwait: make(chan error, 1),
+	}
+	c.start = sync.OnceValue(c.startFunc)
+	c.wait = sync.OnceValue(c.waitFunc)
+	return c
+}
+
+func (c *cmd) Attach() error {
+	c.attach = true
+	return nil
+}
+
+func (c *cmd) startFunc() (err error) {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	if c.sess, err = c.cdr.client.NewSession(); err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = c.sess.Close() // Best effort.
+		}
+	}()
+	restore := func() {}
+	if c.attach {
+		if restore, err = c.attachPty(); err != nil {
+			return err
+		}
+	} else {
+		if c.writer, err = c.sess.StdinPipe(); err != nil {
+			return fmt.Errorf("failed to pipe stdin: %w", err)
+		}
+		if c.reader, err = c.sess.StdoutPipe(); err != nil {
+			return fmt.Errorf("failed to pipe stdout: %w", err)
+		}
+		c.sess.Stderr = c.logger
+	}
+	if err = c.sess.Start(remoteCommand(c.ctx, c.env, c.args)); err != nil {
+		restore()
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			c.terminate(done)
+		case <-done:
+		}
+	}()
+	go func() {
+		err := c.sess.Wait()
+		close(done)
+		restore()
+		_ = c.sess.Close()
+		c.cmdwait <- err
+	}()
+	return nil
+}
+
+var signals = map[os.Signal]ssh.Signal{
+	os.Interrupt:    ssh.SIGINT,
+	os.Kill:         ssh.SIGKILL,
+	syscall.SIGHUP:  ssh.SIGHUP,
+	syscall.SIGQUIT: ssh.SIGQUIT,
+	syscall.SIGTERM: ssh.SIGTERM,
+}
+
+// terminate stops the remote command according to its [cmdio.Termination].
+// Signals are delivered on a best effort basis.
+func (c *cmd) terminate(done <-chan struct{}) {
+	t := cmdio.TerminationFromContext(c.ctx)
+	if sig, ok := signals[t.Signal]; ok && sig != ssh.SIGKILL {
+		_ = c.sess.Signal(sig)
+		if t.Grace <= 0 {
+			return
+		}
+		select {
+		case <-time.After(t.Grace):
+		case <-done:
+			return
+		}
+	}
+	_ = c.sess.Signal(ssh.SIGKILL)
+	_ = c.sess.Close()
+}
+
+// attachPty connects the session to the controlling terminal, allocating a
+// remote pseudo-terminal if standard input is a terminal.
+func (c *cmd) attachPty() (restore func(), err error) {
+	c.sess.Stdin = os.Stdin
+	c.sess.Stdout = os.Stdout
+	c.sess.Stderr = os.Stderr
+	restore = func() {}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return
+	}
+	w, h, err := term.GetSize(fd)
+	if err != nil {
+		w, h = 80, 24
+	}
+	termenv := os.Getenv("TERM")
+	if termenv == "" {
+		termenv = "xterm"
+	}
+	modes := ssh.TerminalModes{ssh.ECHO: 1}
+	if err = c.sess.RequestPty(termenv, h, w, modes); err != nil {
+		return nil, fmt.Errorf("failed to request pty: %w", err)
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set terminal mode: %w", err)
+	}
+	return sync.OnceFunc(func() {
+		_ = term.Restore(fd, state) // Best effort.
+	}), nil
+}
+
+func (c *cmd) Write(bytes []byte) (int, error) {
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	if c.writer == nil {
+		return 0, nil
+	}
+	n, err := c.writer.Write(bytes)
+	if err != nil {
+		return n, fmt.Errorf("failed write: %w", err)
+	}
+	return n, nil
+}
+
+func (c *cmd) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	if c.writer == nil {
+		return nil
+	}
+	if err := c.writer.Close(); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed close: %w", err)
+	}
+	return nil
+}
+
+func (c *cmd) Read(bytes []byte) (n int, err error) {
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	if c.reader == nil {
+		err = io.EOF
+	} else {
+		n, err = c.reader.Read(bytes)
+	}
+	if err != nil {
+		if err1 := c.wait(); err1 != nil {
+			err = err1
+		}
+	}
+	return n, err
+}
+
+func (c *cmd) Log(w io.Writer) {
+	c.logger = w
+}
+
+func (c *cmd) Code() int {
+	return c.code
+}
+
+func (c *cmd) waitFunc() error {
+	err := <-c.cmdwait
+	if ee := new(ssh.ExitError); errors.As(err, &ee) {
+		c.code = ee.ExitStatus()
+	}
+	if err != nil && c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	return err
+}
+
+func (c *cmd) Context() context.Context {
+	return c.ctx
+}
+
+func (c *cmd) String() string {
+	addr := c.cdr.client.User() + "@" + c.cdr.client.RemoteAddr().String()
+	return cmdio.Redact(c.ctx, "ssh "+addr+" "+sh.String(c.ctx, c.env, c.args))
+}
+
+// remoteCommand renders a command for execution by a remote shell.
+func remoteCommand(
+	ctx context.Context, env map[string]string, args []string,
+) string {
+	var b strings.Builder
+	set, unset, clean := cmdio.ParseEnv(ctx, env)
+	if dir, ok := set["PWD"]; ok {
+		b.WriteString("cd " + sh.Quote(dir) + " && ")
+	}
+	b.WriteString("exec ")
+	var envs []string
+	if clean {
+		envs = append(envs, "-i")
+	}
+	for _, k := range unset {
+		envs = append(envs, "-u", k)
+	}
+	for _, k := range sh.SortKeys(set) {
+		if k != "PWD" {
+			envs = append(envs, k+"="+set[k])
+		}
+	}
+	if len(envs) > 0 {
+		b.WriteString("env " + sh.Join(envs) + " ")
+	}
+	b.WriteString(sh.Join(args))
+	return b.String()
+}
@@ -0,0 +1,45 @@
+// Package ssh provides a [cmdio.Runner] that runs commands on remote hosts.
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+	"lesiw.io/cmdio"
+)
+
+type cdr struct {
+	client *ssh.Client
+}
+
+func (c *cdr) Command(
+	ctx context.Context, env map[string]string, args ...string,
+) cmdio.Command {
+	return newCmd(c, ctx, env, args...)
+}
+
+func (c *cdr) Close() error {
+	return c.client.Close()
+}
+
+// New instantiates a [cmdio.Runner] that runs commands using the given SSH
+// client. Closing the Runner closes the client.
+//
+// Commands are run by the remote user's shell. The PWD environment variable
+// sets the remote working directory.
+func New(client *ssh.Client) *cmdio.Runner {
+	return new(cmdio.Runner).
+		WithCommander(&cdr{client}).
+		WithContext(context.Background())
+}
+
+// Dial connects to the SSH server at addr and instantiates a [cmdio.Runner]
+// that runs commands on it.
+func Dial(addr string, config *ssh.ClientConfig) (*cmdio.Runner, error) {
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to '%s': %w", addr, err)
+	}
+	return New(client), nil
+}
@@ -0,0 +1,195 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"lesiw.io/cmdio"
+)
+
+func TestGet(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(testServer(t))
+	defer rnr.Close()
+
+	r, err := rnr.Get("sh", "-c", "echo hello world; echo hello stderr >&2")
+
+	if err != nil {
+		t.Errorf("rnr.Get().error = %q, want <nil>", err)
+	}
+	if got, want := r.Out, "hello world"; got != want {
+		t.Errorf("rnr.Get().Result.Out = %q, want %q", got, want)
+	}
+	if got, want := r.Log, "hello stderr"; got != want {
+		t.Errorf("rnr.Get().Result.Log = %q, want %q", got, want)
+	}
+}
+
+func TestGetFailure(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(testServer(t))
+	defer rnr.Close()
+
+	r, err := rnr.Get("sh", "-c", "exit 42")
+
+	if err == nil {
+		t.Errorf("rnr.Get().error = <nil>, want error")
+	} else if ee := new(ssh.ExitError); !errors.As(err, &ee) {
+		t.Errorf("rnr.Get().error = %T, want *ssh.ExitError", err)
+	}
+	if got, want := r.Code, 42; got != want {
+		t.Errorf("rnr.Get().Result.Code = %d, want %d", got, want)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(testServer(t)).WithEnv(map[string]string{
+		"PWD":      "/tmp",
+		"TEST_ENV": "it's a test",
+	})
+	defer rnr.Close()
+
+	r, err := rnr.Get("sh", "-c", `echo "$(pwd) $TEST_ENV"`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Out, "/tmp it's a test"; got != want {
+		t.Errorf("rnr.Get().Result.Out = %q, want %q", got, want)
+	}
+	if got, want := rnr.Env("TEST_ENV"), "it's a test"; got != want {
+		t.Errorf("rnr.Env(TEST_ENV) = %q, want %q", got, want)
+	}
+}
+
+func TestEnvClean(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(testServer(t)).WithCleanEnv().WithEnv(map[string]string{
+		"TEST_ENV":  "testenv",
+		"TEST_ENV2": "unset",
+	}).WithoutEnv("TEST_ENV2")
+	defer rnr.Close()
+
+	r, err := rnr.Get("env")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Out, "TEST_ENV=testenv"; got != want {
+		t.Errorf("[env] = %q, want %q", got, want)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(testServer(t))
+	defer rnr.Close()
+
+	r, err := cmdio.GetPipe(
+		strings.NewReader("hello world"),
+		rnr.Command("tr", "a-z", "A-Z"),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Out, "HELLO WORLD"; got != want {
+		t.Errorf("GetPipe().Result.Out = %q, want %q", got, want)
+	}
+}
+
+func TestContext(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	rnr := New(testServer(t)).WithContext(ctx)
+	defer rnr.Close()
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := rnr.Get("sleep", "5")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("rnr.Get(sleep 5).error = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("rnr.Get(sleep 5) took %v after cancellation", d)
+	}
+}
+
+func TestTermination(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := New(testServer(t)).WithTimeout(100 * time.Millisecond).
+		WithTermination(cmdio.Termination{
+			Signal: syscall.SIGTERM,
+			Grace:  5 * time.Second,
+		})
+	defer rnr.Close()
+
+	start := time.Now()
+	r, err := rnr.Get("sh", "-c",
+		"trap 'exit 3' TERM; while :; do sleep 0.01; done")
+
+	if !errors.Is(err, cmdio.ErrTimeout) {
+		t.Errorf("rnr.Get().error = %v, want cmdio.ErrTimeout", err)
+	}
+	if got, want := r.Code, 3; got != want {
+		t.Errorf("rnr.Get().Result.Code = %d, want %d", got, want)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("rnr.Get() took %v, want graceful exit", d)
+	}
+}
+
+func TestString(t *testing.T) {
+	client := testServer(t)
+	rnr := New(client).WithEnv(map[string]string{"PWD": "/tmp"})
+	defer rnr.Close()
+
+	cmd := rnr.Command("echo", "hello world")
+
+	str := fmt.Sprintf("ssh test@%s PWD=/tmp echo 'hello world'",
+		client.RemoteAddr())
+	if got, want := fmt.Sprint(cmd), str; got != want {
+		t.Errorf("Sprint(cmd) = %q, want %q", got, want)
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		args []string
+		want string
+	}{{
+		args: []string{"echo", "hello world"},
+		want: "exec echo 'hello world'",
+	}, {
+		env:  map[string]string{"PWD": "/my dir", "B": "2", "A": "1"},
+		args: []string{"pwd"},
+		want: "cd '/my dir' && exec env A=1 B=2 pwd",
+	}, {
+		args: []string{"echo", "it's"},
+		want: `exec echo 'it'\''s'`,
+	}}
+	for _, tt := range tests {
+		got := remoteCommand(context.Background(), tt.env, tt.args)
+		if got != tt.want {
+			t.Errorf("remoteCommand(%v, %q) = %q, want %q",
+				tt.env, tt.args, got, tt.want)
+		}
+	}
+}
+
+func swap[T any](t *testing.T, orig *T, with T) {
+	t.Helper()
+	o := *orig
+	t.Cleanup(func() { *orig = o })
+	*orig = with
+}
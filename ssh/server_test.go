@@ -0,0 +1,151 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var serverSignals = map[ssh.Signal]syscall.Signal{
+	ssh.SIGINT:  syscall.SIGINT,
+	ssh.SIGKILL: syscall.SIGKILL,
+	ssh.SIGTERM: syscall.SIGTERM,
+}
+
+// testServer starts an in-process SSH server that runs commands with the
+// local shell and returns a client connected to it.
+func testServer(t *testing.T) *ssh.Client {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(ch, reqs)
+	}
+}
+
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	var proc *exec.Cmd
+	for req := range reqs {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if proc != nil || ssh.Unmarshal(req.Payload, &payload) != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			proc = exec.Command("sh", "-c", payload.Command)
+			proc.Stdout = ch
+			proc.Stderr = ch.Stderr()
+			stdin, err := proc.StdinPipe()
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			if err := proc.Start(); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			go func() {
+				_, _ = io.Copy(stdin, ch)
+				_ = stdin.Close()
+			}()
+			go func() {
+				exit(ch, proc.Wait())
+			}()
+		case "signal":
+			var payload struct{ Signal string }
+			if proc == nil || ssh.Unmarshal(req.Payload, &payload) != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			if sig, ok := serverSignals[ssh.Signal(payload.Signal)]; ok {
+				_ = proc.Process.Signal(sig)
+			}
+			_ = req.Reply(true, nil)
+		case "pty-req":
+			_ = req.Reply(true, nil)
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func exit(ch ssh.Channel, err error) {
+	defer ch.Close()
+	var status struct{ Status uint32 }
+	if ee := new(exec.ExitError); errors.As(err, &ee) {
+		ws := ee.Sys().(syscall.WaitStatus)
+		if ws.Signaled() {
+			for name, sig := range serverSignals {
+				if sig == ws.Signal() {
+					_, _ = ch.SendRequest("exit-signal", false,
+						ssh.Marshal(struct {
+							Signal     string
+							CoreDumped bool
+							Error      string
+							Lang       string
+						}{Signal: string(name)}))
+					return
+				}
+			}
+		}
+		status.Status = uint32(ee.ExitCode())
+	} else if err != nil {
+		status.Status = 255
+	}
+	_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(status))
+}
@@ -1,41 +1,53 @@
 package sys
 
 import (
-	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"slices"
-	"strings"
 	"sync"
+	"time"
 
 	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/internal/sh"
 )
 
 type cmd struct {
 	cmdio.Command
 
+	cdr  *cdr
 	ctx  context.Context
 	cmd  *exec.Cmd
 	env  map[string]string
+	term cmdio.Termination
 	code int
 
 	cmdwait chan error
+	waited  chan struct{} // Closed once the process has exited.
+	exited  chan struct{} // Closed once its output has been consumed, too.
+	logdone chan struct{}
+
+	attached bool
+	group    bool
+
+	tty  bool
+	mu   sync.Mutex
+	ptmx *os.File
+	rows int
+	cols int
 
 	start func() error
 	wait  func() error
 
-	reader io.ReadCloser
+	reader *os.File
 	writer io.WriteCloser
 	logger io.Writer
-
-	closers []io.Closer
 }
 
 func (c *cmd) Attach() error {
+	c.attached = true
 	c.cmd.Stdin = os.Stdin
 	c.cmd.Stdout = os.Stdout
 	c.cmd.Stderr = os.Stderr
@@ -43,26 +55,60 @@ func (c *cmd) Attach() error {
 }
 
 func newCmd(
-	ctx context.Context, env map[string]string, args ...string,
+	cdr *cdr, ctx context.Context, env map[string]string, args ...string,
 ) cmdio.Command {
 	c := new(cmd)
+	c.cdr = cdr
+	c.tty = cdr.tty
+	c.rows, c.cols = 24, 80
 	c.ctx = ctx
 	c.cmd = exec.CommandContext(ctx, args[0], args[1:]...)
 	c.env = env
-	if dir, ok := env["PWD"]; ok {
+	if dir := env["PWD"]; dir != "" {
 		c.cmd.Dir = dir
 	}
-	c.cmd.Env = os.Environ()
-	for k, v := range env {
-		c.cmd.Env = append(c.cmd.Env, k+"="+v)
-	}
+	c.cmd.Env = cmdio.Environ(ctx, os.Environ(), env)
+	c.term = cmdio.TerminationFromContext(ctx)
+	c.cmd.Cancel = c.terminate
 	c.start = sync.OnceValue(c.startFunc)
 	c.wait = sync.OnceValue(c.waitFunc)
 	c.cmdwait = make(chan error)
+	c.waited = make(chan struct{})
+	c.exited = make(chan struct{})
+	c.logdone = make(chan struct{})
 	return c
 }
 
+// terminate stops the command according to its [cmdio.Termination].
+//
+// The command is always killed along with its process group, if it has one.
+// Other signals are only sent to the process group if the Termination
+// requests it.
+func (c *cmd) terminate() error {
+	if c.term.Signal == nil {
+		return c.signal(os.Kill, true)
+	}
+	err := c.signal(c.term.Signal, c.term.Group)
+	if err == nil && c.term.Grace > 0 {
+		go func() {
+			select {
+			case <-time.After(c.term.Grace):
+				_ = c.signal(os.Kill, true) // Best effort.
+			case <-c.exited:
+			}
+		}()
+	}
+	return err
+}
+
 func (c *cmd) startFunc() error {
+	if c.tty && !c.attached {
+		if err := c.startPTY(); err != nil {
+			return err
+		}
+		c.started(nil)
+		return nil
+	}
 	if c.cmd.Stdin == nil {
 		w, err := c.cmd.StdinPipe()
 		if err != nil {
@@ -70,31 +116,88 @@ func (c *cmd) startFunc() error {
 		}
 		c.writer = w
 	}
+	// Output is passed through OS pipes rather than copied by os/exec, so that
+	// Wait returns as soon as the command exits, even if its orphaned children
+	// still hold the pipes open. See readPipe.
+	var (
+		child []io.Closer
+		logr  *os.File
+	)
 	if c.cmd.Stdout == nil {
-		r, w := io.Pipe()
+		r, w, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("failed to pipe stdout: %w", err)
+		}
 		c.reader = r
 		c.cmd.Stdout = w
-		c.closers = append(c.closers, w)
+		child = append(child, w)
 	}
-	if c.cmd.Stderr == nil {
-		c.cmd.Stderr = c.logger
+	if c.cmd.Stderr == nil && c.logger != nil {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeAll(child)
+			return fmt.Errorf("failed to pipe stderr: %w", err)
+		}
+		logr = r
+		c.cmd.Stderr = w
+		child = append(child, w)
+	}
+	if c.cdr.groups && !c.attached {
+		// Attached commands must remain in the terminal's process group.
+		c.group = setpgid(c.cmd)
 	}
 	if err := c.cmd.Start(); err != nil {
-		for _, cl := range c.closers {
-			_ = cl.Close() // Best effort.
+		closeAll(child)
+		if c.reader != nil {
+			_ = c.reader.Close()
+		}
+		if logr != nil {
+			_ = logr.Close()
 		}
 		return err
 	}
+	closeAll(child)
+	c.started(logr)
+	return nil
+}
+
+// started monitors a command that has started, copying logr to the logger if
+// it is non-nil.
+func (c *cmd) started(logr *os.File) {
+	if logr != nil {
+		go func() {
+			_, _ = io.Copy(c.logger, readerFunc(func(p []byte) (int, error) {
+				return c.readPipe(logr, p)
+			}))
+			_ = logr.Close()
+			close(c.logdone)
+		}()
+	} else {
+		close(c.logdone)
+	}
+	c.cdr.track(c)
 	go func() {
 		err := c.cmd.Wait()
-		for _, cl := range c.closers {
-			if err1 := cl.Close(); err == nil {
-				err = err1
-			}
+		close(c.waited)
+		// Stop waiting for output from any children left running.
+		deadline := time.Now().Add(orphanDelay)
+		if c.reader != nil {
+			_ = c.reader.SetReadDeadline(deadline)
+		}
+		if logr != nil {
+			_ = logr.SetReadDeadline(deadline)
 		}
+		<-c.logdone
+		close(c.exited)
+		c.cdr.untrack(c)
 		c.cmdwait <- err
 	}()
-	return nil
+}
+
+func closeAll(closers []io.Closer) {
+	for _, cl := range closers {
+		_ = cl.Close() // Best effort.
+	}
 }
 
 func (c *cmd) Write(bytes []byte) (int, error) {
@@ -130,7 +233,7 @@ func (c *cmd) Read(bytes []byte) (int, error) {
 	if err := c.start(); err != nil {
 		return 0, err
 	}
-	ch := make(chan ioret)
+	ch := make(chan ioret, 1)
 	var n int
 	var err error
 	if c.reader == nil {
@@ -139,11 +242,16 @@ func (c *cmd) Read(bytes []byte) (int, error) {
 	}
 
 	go func() {
-		n, err := c.reader.Read(bytes)
+		n, err := c.readPipe(c.reader, bytes)
+		if c.tty {
+			err = ptyErr(err)
+		}
 		ch <- ioret{n, err}
 	}()
 	select {
 	case <-c.ctx.Done():
+		// Discard any further output so the command can be reaped.
+		go func() { _, _ = io.Copy(io.Discard, c.reader) }()
 		n = 0
 		err = io.EOF
 	case ret := <-ch:
@@ -156,10 +264,46 @@ skipread:
 		if err1 := c.wait(); err1 != nil {
 			err = err1
 		}
+		if c.reader != nil {
+			_ = c.reader.Close() // Unblock any pending reads.
+		}
+	}
+	return n, err
+}
+
+// orphanDelay is how long a command's output is waited for once it has
+// exited. Children that it leaves running in the background may hold its
+// output open indefinitely.
+const orphanDelay = 50 * time.Millisecond
+
+// readPipe reads the output of the command from f. Once the command has
+// exited, it returns io.EOF as soon as no more output arrives within
+// orphanDelay, rather than when every process holding f open has exited.
+func (c *cmd) readPipe(f *os.File, p []byte) (int, error) {
+	select {
+	case <-c.waited:
+		_ = f.SetReadDeadline(time.Now().Add(orphanDelay))
+	default:
+	}
+	n, err := f.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = io.EOF
 	}
 	return n, err
 }
 
+// Resize sets the window size of a command's pseudo-terminal.
+// It has no effect on commands without a pseudo-terminal.
+func (c *cmd) Resize(rows, cols int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rows, c.cols = rows, cols
+	if c.ptmx == nil {
+		return nil
+	}
+	return setsize(c.ptmx, rows, cols)
+}
+
 func (c *cmd) Log(w io.Writer) {
 	c.logger = w
 }
@@ -176,27 +320,19 @@ func (c *cmd) waitFunc() error {
 	return err
 }
 
+func (c *cmd) Context() context.Context {
+	return c.ctx
+}
+
 func (c *cmd) String() string {
-	ret := new(strings.Builder)
-	for _, k := range sortkeys(c.env) {
-		ret.WriteString(k + "=" + c.env[k] + " ")
-	}
-	ret.WriteString(shJoin(c.cmd.Args))
-	return ret.String()
+	return cmdio.Redact(c.ctx, sh.String(c.ctx, c.env, c.cmd.Args))
 }
 
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
 type ioret struct {
 	n   int
 	err error
 }
-
-func sortkeys[K cmp.Ordered, V any](m map[K]V) []K {
-	keys := make([]K, len(m))
-	var i int
-	for k := range m {
-		keys[i] = k
-		i++
-	}
-	slices.Sort(keys)
-	return keys
-}
@@ -0,0 +1,21 @@
+package sys
+
+import (
+	"os"
+	"strings"
+)
+
+func (c *cdr) Env(name string) string {
+	return os.Getenv(name)
+}
+
+func (c *cdr) Environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		// On Windows, variables named "=C:" track per-drive directories.
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			env[k] = v
+		}
+	}
+	return env
+}
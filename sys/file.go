@@ -0,0 +1,34 @@
+package sys
+
+import (
+	"context"
+	"path/filepath"
+
+	"lesiw.io/cmdio/internal/archive"
+)
+
+func (c *cdr) CopyTo(
+	ctx context.Context, env map[string]string, src, dst string,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return archive.Copy(src, abs(env, dst))
+}
+
+func (c *cdr) CopyFrom(
+	ctx context.Context, env map[string]string, src, dst string,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return archive.Copy(abs(env, src), dst)
+}
+
+// abs resolves name relative to the PWD in env, if set.
+func abs(env map[string]string, name string) string {
+	if dir, ok := env["PWD"]; ok && !filepath.IsAbs(name) {
+		return filepath.Join(dir, name)
+	}
+	return name
+}
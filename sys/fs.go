@@ -0,0 +1,55 @@
+package sys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"lesiw.io/cmdio/rfs"
+)
+
+// FS implements [rfs.Provider] with direct access to the local file system.
+func (c *cdr) FS(dir string) rfs.WriteFS {
+	return dirFS{os.DirFS(dir), dir}
+}
+
+type dirFS struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (d dirFS) path(name string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(name))
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	return d.fsys.Open(name)
+}
+
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(d.fsys, name)
+}
+
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(d.fsys, name)
+}
+
+func (d dirFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(d.fsys, name)
+}
+
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(d.path(name), data, perm)
+}
+
+func (d dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(d.path(name), perm)
+}
+
+func (d dirFS) Remove(name string) error {
+	return os.Remove(d.path(name))
+}
+
+func (d dirFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(d.path(name), mode)
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package sys
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setpgid reports false: process groups are not supported on this platform.
+func setpgid(*exec.Cmd) bool {
+	return false
+}
+
+// signal sends sig to the command.
+func (c *cmd) signal(sig os.Signal, _ bool) error {
+	if c.cmd.Process == nil {
+		return os.ErrProcessDone
+	}
+	return c.cmd.Process.Signal(sig)
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package sys
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setpgid places cmd in a new process group and reports whether it did so.
+func setpgid(cmd *exec.Cmd) bool {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setpgid = true
+	return true
+}
+
+// signal sends sig to the command, or to its entire process group if group
+// is true and the command has one.
+func (c *cmd) signal(sig os.Signal, group bool) error {
+	if c.cmd.Process == nil {
+		return os.ErrProcessDone
+	}
+	s, ok := sig.(syscall.Signal)
+	if !group || !c.group || !ok {
+		return c.cmd.Process.Signal(sig)
+	}
+	err := syscall.Kill(-c.cmd.Process.Pid, s)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+//go:build unix
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"lesiw.io/cmdio"
+)
+
+func TestTermination(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr := GroupRunner().
+		WithTimeout(200 * time.Millisecond).
+		WithTermination(cmdio.Termination{
+			Signal: syscall.SIGTERM,
+			Grace:  5 * time.Second,
+			Group:  true,
+		})
+
+	start := time.Now()
+	r, err := rnr.Get("sh", "-c", `trap 'exit 3' TERM; sleep 10 & wait`)
+
+	if !errors.Is(err, cmdio.ErrTimeout) {
+		t.Errorf("rnr.Get().error = %v, want cmdio.ErrTimeout", err)
+	}
+	if got, want := r.Code, 3; got != want {
+		t.Errorf("rnr.Get().Result.Code = %d, want %d", got, want)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("rnr.Get() took %v, want graceful exit", d)
+	}
+}
+
+func TestTerminationGrace(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr := Runner().WithTimeout(100 * time.Millisecond).WithTermination(
+		cmdio.Termination{
+			Signal: syscall.SIGTERM,
+			Grace:  100 * time.Millisecond,
+		},
+	)
+
+	start := time.Now()
+	_, err := rnr.Get("sh", "-c", `trap '' TERM; while :; do sleep 0.01; done`)
+
+	if !errors.Is(err, cmdio.ErrTimeout) {
+		t.Errorf("rnr.Get().error = %v, want cmdio.ErrTimeout", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("rnr.Get() took %v, want kill after grace period", d)
+	}
+}
+
+func TestOrphanOutput(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr := Runner()
+
+	start := time.Now()
+	r, err := rnr.Get("sh", "-c", `sleep 10 & echo $!; echo hi >&2`)
+
+	if err != nil {
+		t.Fatalf("rnr.Get() err = %v", err)
+	}
+	pid := atoi(t, r.Out)
+	defer syscall.Kill(pid, syscall.SIGKILL)
+	if got, want := r.Log, "hi"; got != want {
+		t.Errorf("rnr.Get().Log = %q, want %q", got, want)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("rnr.Get() took %v, want return on exit", d)
+	}
+}
+
+func TestOrphanSurvives(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr := Runner()
+
+	r := rnr.MustGet("sh", "-c", `sleep 10 >/dev/null 2>&1 & echo $!`)
+
+	pid := atoi(t, r.Out)
+	defer syscall.Kill(pid, syscall.SIGKILL)
+	time.Sleep(100 * time.Millisecond)
+	if !running(pid) {
+		t.Errorf("background process %d killed, want running", pid)
+	}
+}
+
+func TestCancelKillsGroup(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := GroupRunner().WithContext(ctx).
+		Command("sh", "-c", `sleep 10 & echo $!; wait`)
+	buf := make([]byte, 64)
+	n, err := cmd.Read(buf)
+	if err != nil {
+		t.Fatalf("cmd.Read() err = %v", err)
+	}
+
+	cancel()
+
+	pid := atoi(t, strings.TrimSpace(string(buf[:n])))
+	waitGone(t, pid)
+}
+
+func TestCloseKillsGroup(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr := GroupRunner()
+	cmd := rnr.Command("sh", "-c", `sleep 10 & echo $!; wait`)
+	buf := make([]byte, 64)
+	n, err := cmd.Read(buf)
+	if err != nil {
+		t.Fatalf("cmd.Read() err = %v", err)
+	}
+
+	if err := rnr.Close(); err != nil {
+		t.Fatalf("rnr.Close() err = %v", err)
+	}
+
+	pid := atoi(t, strings.TrimSpace(string(buf[:n])))
+	waitGone(t, pid)
+	if _, err := io.Copy(io.Discard, cmd); err == nil {
+		t.Errorf("cmd error = <nil>, want killed")
+	}
+}
+
+func TestSharedGroup(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+
+	r := Runner().MustGet("sh", "-c", `ps -o pgid= -p $$`)
+
+	pgid := atoi(t, strings.TrimSpace(r.Out))
+	if got, want := pgid, syscall.Getpgrp(); got != want {
+		t.Errorf("command process group = %d, want %d", got, want)
+	}
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("strconv.Atoi(%q) err = %v", s, err)
+	}
+	return n
+}
+
+func waitGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for running(pid) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("process %d still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// running reports whether pid is running. Zombies are not running.
+func running(pid int) bool {
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).
+		Output()
+	return err == nil && !strings.HasPrefix(string(out), "Z")
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package sys
+
+import (
+	"errors"
+	"os"
+)
+
+func (c *cmd) startPTY() error {
+	return errors.ErrUnsupported
+}
+
+func setsize(*os.File, int, int) error {
+	return errors.ErrUnsupported
+}
+
+func ptyErr(err error) error {
+	return err
+}
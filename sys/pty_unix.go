@@ -0,0 +1,72 @@
+//go:build unix
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// startPTY starts the command in a new session with a pseudo-terminal as its
+// controlling terminal.
+func (c *cmd) startPTY() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open pty: %w", err)
+	}
+	defer tty.Close()
+	if err := setsize(ptmx, c.rows, c.cols); err != nil {
+		_ = ptmx.Close()
+		return err
+	}
+	c.cmd.Stdin, c.cmd.Stdout, c.cmd.Stderr = tty, tty, tty
+	if c.cmd.SysProcAttr == nil {
+		c.cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	c.cmd.SysProcAttr.Setsid = true
+	c.cmd.SysProcAttr.Setctty = true
+	if err := c.cmd.Start(); err != nil {
+		_ = ptmx.Close()
+		return err
+	}
+	// A session leader is also the leader of its process group.
+	c.group = true
+	c.ptmx = ptmx
+	c.reader = ptmx
+	c.writer = ptyWriter{ptmx}
+	return nil
+}
+
+func setsize(ptmx *os.File, rows, cols int) error {
+	ws := &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}
+	err := pty.Setsize(ptmx, ws)
+	if err != nil {
+		return fmt.Errorf("failed to set pty size: %w", err)
+	}
+	return nil
+}
+
+// ptyErr translates the error returned by a pseudo-terminal once its command
+// has exited.
+func ptyErr(err error) error {
+	if errors.Is(err, syscall.EIO) {
+		return io.EOF
+	}
+	return err
+}
+
+// ptyWriter writes to a pseudo-terminal.
+// Closing it sends end-of-file rather than closing the terminal.
+type ptyWriter struct{ *os.File }
+
+func (w ptyWriter) Close() error {
+	_, err := w.Write([]byte{4}) // ^D
+	return err
+}
@@ -0,0 +1,65 @@
+//go:build unix
+
+package sys
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"lesiw.io/cmdio"
+)
+
+func TestPTY(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	rnr := PTY()
+
+	r, err := rnr.Get("sh", "-c", `test -t 0 && test -t 1 && echo tty; exit 3`)
+
+	if err == nil {
+		t.Errorf("rnr.Get() err = <nil>, want exit status 3")
+	}
+	if got, want := strings.TrimSpace(r.Out), "tty"; got != want {
+		t.Errorf("rnr.Get().Out = %q, want %q", got, want)
+	}
+	if got, want := r.Code, 3; got != want {
+		t.Errorf("rnr.Get().Code = %d, want %d", got, want)
+	}
+}
+
+func TestPTYResize(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	cmd := PTY().Command("stty", "size")
+
+	if err := cmd.(cmdio.Resizer).Resize(40, 100); err != nil {
+		t.Fatalf("Resize() err = %v", err)
+	}
+	out, err := io.ReadAll(cmd)
+
+	if err != nil {
+		t.Fatalf("io.ReadAll(cmd) err = %v", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "40 100"; got != want {
+		t.Errorf("stty size = %q, want %q", got, want)
+	}
+}
+
+func TestPTYInput(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	cmd := PTY().Command("sh", "-c", `stty -echo; read x; echo "got $x"; cat`)
+
+	if _, err := io.WriteString(cmd, "hello\n"); err != nil {
+		t.Fatalf("io.WriteString(cmd) err = %v", err)
+	}
+	if err := cmd.(io.Closer).Close(); err != nil {
+		t.Fatalf("cmd.Close() err = %v", err)
+	}
+	out, err := io.ReadAll(cmd)
+
+	if err != nil {
+		t.Fatalf("io.ReadAll(cmd) err = %v", err)
+	}
+	if got, want := string(out), "got hello"; !strings.Contains(got, want) {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -2,21 +2,93 @@ package sys
 
 import (
 	"context"
+	"os"
+	"sync"
 
 	"lesiw.io/cmdio"
 )
 
-type cdr struct{}
+type cdr struct {
+	tty    bool
+	groups bool // Whether unattached commands run in their own process groups.
 
-func (cdr) Command(
+	mu   sync.Mutex
+	cmds map[*cmd]struct{}
+}
+
+func (c *cdr) Command(
 	ctx context.Context, env map[string]string, args ...string,
 ) cmdio.Command {
-	return newCmd(ctx, env, args...)
+	return newCmd(c, ctx, env, args...)
+}
+
+func (c *cdr) track(cm *cmd) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmds == nil {
+		c.cmds = make(map[*cmd]struct{})
+	}
+	c.cmds[cm] = struct{}{}
+}
+
+func (c *cdr) untrack(cm *cmd) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cmds, cm)
+}
+
+// Close kills any commands that are still running, along with their process
+// groups, if they have them.
+func (c *cdr) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for cm := range c.cmds {
+		_ = cm.signal(os.Kill, true) // Best effort.
+	}
+	return nil
 }
 
 // Runner instantiates a [cmdio.Runner] that runs commands on the local system.
+//
+// Canceling a command, timing it out, or closing the Runner kills the command,
+// but not any processes it started. Processes that a command leaves running in
+// the background once it exits are not killed, but its output is only read
+// until shortly after it exits.
 func Runner() *cmdio.Runner {
 	return new(cmdio.Runner).
 		WithCommander(new(cdr)).
 		WithContext(context.Background())
 }
+
+// GroupRunner instantiates a [cmdio.Runner] like [Runner], except that on Unix
+// systems, each command that is not attached to the terminal runs in its own
+// process group, so that canceling it, timing it out, or closing the Runner
+// also kills any processes it started.
+//
+// Because of the separate process group, signals from the terminal, such as
+// the interrupt sent by Ctrl-C, do not reach these commands, and they keep
+// running if the program exits without closing the Runner. Programs should
+// cancel their commands' context or close the Runner on such signals, as with
+// [os/signal.NotifyContext].
+func GroupRunner() *cmdio.Runner {
+	return new(cmdio.Runner).
+		WithCommander(&cdr{groups: true}).
+		WithContext(context.Background())
+}
+
+// PTY instantiates a [cmdio.Runner] that runs commands on the local system
+// in a pseudo-terminal, for programs that behave differently when they are not
+// run in a terminal.
+//
+// Reading from a command reads both its standard output and its standard
+// error, and writing to a command writes to its terminal, which echoes the
+// input by default. Closing a command sends end-of-file. Commands implement
+// [cmdio.Resizer] to set the terminal window size, which defaults to 24 rows
+// and 80 columns.
+//
+// Pseudo-terminals are supported only on Unix systems.
+func PTY() *cmdio.Runner {
+	return new(cmdio.Runner).
+		WithCommander(&cdr{tty: true}).
+		WithContext(context.Background())
+}
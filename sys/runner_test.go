@@ -138,6 +138,11 @@ func TestGetFailure(t *testing.T) {
 	} else if got, want := ee.ExitCode(), 42; got != want {
 		t.Errorf("rnr.Get().error.ExitCode() = %d, want %d", got, want)
 	}
+	if ce := new(cmdio.Error); !errors.As(err, &ce) {
+		t.Errorf("rnr.Get().error = %T, want *cmdio.Error", err)
+	} else if got, want := ce.Result.Code, 42; got != want {
+		t.Errorf("rnr.Get().error.Result.Code = %d, want %d", got, want)
+	}
 	if got, want := trc.String(), log.String(); !cmp.Equal(got, want) {
 		t.Errorf("rnr.Get() trace -want +got\n%s", cmp.Diff(want, got))
 	}
@@ -176,6 +181,31 @@ func TestGetBadCommand(t *testing.T) {
 	}
 }
 
+func TestEnvUnsetInherited(t *testing.T) {
+	swap[io.Writer](t, &cmdio.Trace, io.Discard)
+	t.Setenv("CMDIO_TEST_UNSET", "inherited")
+	rnr := Runner()
+
+	if got, want := rnr.Env("CMDIO_TEST_UNSET"), "inherited"; got != want {
+		t.Errorf("rnr.Env() = %q, want %q", got, want)
+	}
+	rnr = rnr.WithoutEnv("CMDIO_TEST_UNSET")
+	if got, want := rnr.Env("CMDIO_TEST_UNSET"), ""; got != want {
+		t.Errorf("rnr.WithoutEnv().Env() = %q, want %q", got, want)
+	}
+}
+
+func TestStringEnv(t *testing.T) {
+	rnr := Runner().WithCleanEnv().WithoutEnv("GOFLAGS").WithEnv(
+		map[string]string{"PWD": "/tmp"},
+	)
+	cmd := rnr.Command("go", "build")
+	str := "env -i -u GOFLAGS PWD=/tmp go build"
+	if got, want := fmt.Sprint(cmd), str; got != want {
+		t.Errorf("Sprint(cmd) = %q, want %q", got, want)
+	}
+}
+
 func swap[T any](t *testing.T, orig *T, with T) {
 	t.Helper()
 	o := *orig
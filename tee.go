@@ -0,0 +1,180 @@
+package cmdio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// A Branch is a linear chain of streams within a [Tee].
+// Input to the branch flows through each stream in Mid to Dst, as if by
+// [Copy].
+type Branch struct {
+	Mid []io.ReadWriter
+	Dst io.Writer
+}
+
+// A BranchError describes a failure in one branch of a [Tee] or [Merge].
+type BranchError struct {
+	// Branch is the index of the failing branch.
+	Branch int
+	// Offset is the index of the failing stream within the branch. In a Tee,
+	// the shared source is at index 0 and the branch's first stream is at
+	// index 1. In a Merge, it is always 0.
+	Offset int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *BranchError) Error() string {
+	return fmt.Sprintf("branch %d: %v", e.Branch, e.Err)
+}
+
+func (e *BranchError) Unwrap() error {
+	return e.Err
+}
+
+// Tee copies the output of src into each branch concurrently, like the tee
+// command. When src is exhausted, the first stream of each branch is closed if
+// it is an [io.Closer].
+//
+// A branch that fails stops receiving input, but the others continue. Once
+// every branch has failed, the rest of src is discarded. If a branch fails,
+// the returned error is a [*BranchError]. If src fails, the returned error
+// reports an offset of 0, as if returned by [Copy].
+func Tee(src io.Reader, branch ...Branch) error {
+	var g errgroup.Group
+	ws := make([]io.Writer, len(branch))
+	for i, b := range branch {
+		if len(b.Mid) == 0 {
+			ws[i] = b.Dst
+			continue
+		}
+		ws[i] = b.Mid[0]
+		g.Go(func() error {
+			_, _, err := copyStages(b.Dst, b.Mid[0], b.Mid[1:], nil)
+			if err == nil {
+				return nil
+			}
+			// Errors closing Dst are not copyErrors.
+			off := len(b.Mid) + 1
+			if cerr, ok := err.(copyError); ok {
+				off, err = cerr.off+1, cerr.err
+			}
+			return &BranchError{Branch: i, Offset: off, Err: err}
+		})
+	}
+	g.Go(func() error { return tee(src, ws) })
+	return g.Wait()
+}
+
+// tee copies src into each of ws, then closes them.
+func tee(src io.Reader, ws []io.Writer) error {
+	var (
+		buf  = make([]byte, 32*1024)
+		errs = make([]error, len(ws))
+		live = len(ws)
+		err  error
+	)
+	for live > 0 {
+		n, rerr := src.Read(buf)
+		for i, w := range ws {
+			if errs[i] != nil || n == 0 {
+				continue
+			}
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				errs[i] = werr
+				live--
+			}
+		}
+		if rerr == io.EOF {
+			break
+		} else if rerr != nil {
+			err = copyError{rerr, 0}
+			break
+		}
+	}
+	if live == 0 {
+		// Let src finish, as if its output were discarded.
+		_, _ = io.Copy(io.Discard, src)
+	}
+	for i, w := range ws {
+		if c, ok := w.(io.Closer); ok {
+			if cerr := c.Close(); errs[i] == nil {
+				errs[i] = cerr
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for i, werr := range errs {
+		if werr != nil {
+			return &BranchError{Branch: i, Offset: 1, Err: werr}
+		}
+	}
+	return nil
+}
+
+// Merge returns a reader that yields the output of each src as it is read
+// concurrently, like several commands writing to one pipe. Output from
+// different sources is interleaved in arbitrarily sized chunks.
+//
+// If a source fails, reading from the returned reader fails with a
+// [*BranchError], and the output of the remaining sources is discarded.
+func Merge(src ...io.Reader) io.Reader {
+	m := &merge{src: src}
+	m.start = sync.OnceFunc(m.run)
+	return m
+}
+
+type merge struct {
+	src   []io.Reader
+	start func()
+	r     *io.PipeReader
+	w     *io.PipeWriter
+}
+
+func (m *merge) run() {
+	m.r, m.w = io.Pipe()
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+	)
+	fail := func(err error) {
+		once.Do(func() { _ = m.w.CloseWithError(err) })
+	}
+	for i, r := range m.src {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := io.Copy(m.w, r); err != nil {
+				if !errors.Is(err, io.ErrClosedPipe) {
+					fail(&BranchError{Branch: i, Err: err})
+				}
+				_, _ = io.Copy(io.Discard, r) // Reap the source.
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		fail(nil)
+	}()
+}
+
+func (m *merge) Read(p []byte) (int, error) {
+	m.start()
+	return m.r.Read(p)
+}
+
+func (m *merge) String() string {
+	s := make([]string, len(m.src))
+	for i, r := range m.src {
+		s[i] = cmdString(r)
+	}
+	return "{ " + strings.Join(s, " & ") + "; }"
+}
@@ -0,0 +1,134 @@
+package cmdio_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+func TestTee(t *testing.T) {
+	rnr := sys.Runner()
+	var raw, upper bytes.Buffer
+
+	err := cmdio.Tee(strings.NewReader("hello world"),
+		cmdio.Branch{Dst: &raw},
+		cmdio.Branch{
+			Mid: []io.ReadWriter{rnr.Command("tr", "a-z", "A-Z")},
+			Dst: &upper,
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("Tee() err = %v", err)
+	}
+	if got, want := raw.String(), "hello world"; got != want {
+		t.Errorf("branch 0 = %q, want %q", got, want)
+	}
+	if got, want := upper.String(), "HELLO WORLD"; got != want {
+		t.Errorf("branch 1 = %q, want %q", got, want)
+	}
+}
+
+func TestTeeBranchError(t *testing.T) {
+	rnr := sys.Runner()
+	var ok, out bytes.Buffer
+
+	err := cmdio.Tee(strings.NewReader("hello world"),
+		cmdio.Branch{Dst: &ok},
+		cmdio.Branch{
+			Mid: []io.ReadWriter{
+				rnr.Command("cat"),
+				rnr.Command("sh", "-c", "cat >/dev/null; exit 1"),
+			},
+			Dst: &out,
+		},
+	)
+
+	var e *cmdio.BranchError
+	if !errors.As(err, &e) {
+		t.Fatalf("Tee() err = %v, want *cmdio.BranchError", err)
+	}
+	if got, want := e.Branch, 1; got != want {
+		t.Errorf("BranchError.Branch = %d, want %d", got, want)
+	}
+	if got, want := e.Offset, 2; got != want {
+		t.Errorf("BranchError.Offset = %d, want %d", got, want)
+	}
+	if got, want := ok.String(), "hello world"; got != want {
+		t.Errorf("branch 0 = %q, want %q", got, want)
+	}
+}
+
+func TestTeeSourceError(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := cmdio.Tee(iotest.ErrReader(errors.New("some error")),
+		cmdio.Branch{Dst: &buf},
+	)
+
+	off, ok := err.(interface{ Offset() int })
+	if !ok {
+		t.Fatalf("Tee() err = %v, want offset error", err)
+	}
+	if got, want := off.Offset(), 0; got != want {
+		t.Errorf("Tee() err offset = %d, want %d", got, want)
+	}
+}
+
+func TestTeeAllBranchesFail(t *testing.T) {
+	swap(t, &cmdio.Trace, io.Discard)
+	src := sys.Runner().Command("seq", "100000")
+
+	err := cmdio.Tee(src,
+		cmdio.Branch{Dst: failWriter{}},
+		cmdio.Branch{Dst: failWriter{}},
+	)
+
+	var e *cmdio.BranchError
+	if !errors.As(err, &e) {
+		t.Fatalf("Tee() err = %v, want *cmdio.BranchError", err)
+	}
+	if n, _ := src.Read(make([]byte, 64)); n != 0 {
+		t.Errorf("src.Read() = %d bytes, want src drained", n)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	rnr := sys.Runner()
+	var buf bytes.Buffer
+
+	_, err := cmdio.Copy(&buf,
+		cmdio.Merge(rnr.Command("echo", "a"), rnr.Command("echo", "b")),
+		rnr.Command("sort"),
+	)
+
+	if err != nil {
+		t.Fatalf("Copy() err = %v", err)
+	}
+	if got, want := buf.String(), "a\nb\n"; got != want {
+		t.Errorf("Copy() = %q, want %q", got, want)
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	rnr := sys.Runner()
+
+	_, err := io.ReadAll(cmdio.Merge(
+		rnr.Command("echo", "a"),
+		rnr.Command("sh", "-c", "exit 1"),
+	))
+
+	var e *cmdio.BranchError
+	if !errors.As(err, &e) {
+		t.Fatalf("io.ReadAll() err = %v, want *cmdio.BranchError", err)
+	}
+	if got, want := e.Branch, 1; got != want {
+		t.Errorf("BranchError.Branch = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package cmdio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is wrapped by errors from commands that exceed the timeout set
+// with [Runner.WithTimeout].
+var ErrTimeout = errors.New("command timed out")
+
+// A Termination describes how a command is stopped once its context is done.
+//
+// The zero value kills the command immediately. [Commander] implementations
+// retrieve the Termination of a command with [TerminationFromContext].
+type Termination struct {
+	// Signal is sent to the command first. If nil, the command is killed.
+	Signal os.Signal
+	// Grace is how long to wait after Signal is sent before killing the
+	// command. If zero, the command is not killed.
+	Grace time.Duration
+	// Group causes Signal to be sent to the command's entire process group,
+	// where supported. Commanders that manage process groups may always kill
+	// the entire group.
+	Group bool
+}
+
+type termKey struct{}
+
+// TerminationFromContext returns the [Termination] of a command with the
+// given context.
+func TerminationFromContext(ctx context.Context) Termination {
+	t, _ := ctx.Value(termKey{}).(Termination)
+	return t
+}
+
+// WithTimeout creates a new Runner whose commands are canceled if they run for
+// longer than d. Errors from commands that time out wrap [ErrTimeout].
+// The new Runner will otherwise be identical to its parent.
+func (rnr *Runner) WithTimeout(d time.Duration) *Runner {
+	rnr2 := rnr.clone()
+	rnr2.opts.timeout = d
+	return rnr2
+}
+
+// WithTermination creates a new Runner whose commands are stopped according
+// to t when they are canceled or time out.
+// The new Runner will otherwise be identical to its parent.
+func (rnr *Runner) WithTermination(t Termination) *Runner {
+	rnr2 := rnr.clone()
+	rnr2.opts.term = &t
+	return rnr2
+}
+
+// merge returns o with any unset settings taken from o2, and the secrets and
+// unset variables of both.
+func (o options) merge(o2 options) options {
+	if o.timeout == 0 {
+		o.timeout = o2.timeout
+	}
+	if o.term == nil {
+		o.term = o2.term
+	}
+	o.secrets = append(slices.Clip(o.secrets), o2.secrets...)
+	for _, name := range o2.unset {
+		o.unset = addName(o.unset, name)
+	}
+	o.clean = o.clean || o2.clean
+	return o
+}
+
+type timeoutCmd struct {
+	Command
+
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	start   func()
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func newTimeoutCmd(
+	ctx context.Context, d time.Duration,
+	cmd func(context.Context) Command,
+) Command {
+	c := &timeoutCmd{timeout: d}
+	c.ctx, c.cancel = context.WithCancelCause(ctx)
+	c.start = sync.OnceFunc(func() {
+		c.timer = time.AfterFunc(d, func() { c.cancel(ErrTimeout) })
+	})
+	c.Command = cmd(c.ctx)
+	return c
+}
+
+func (c *timeoutCmd) Context() context.Context {
+	return c.ctx
+}
+
+func (c *timeoutCmd) Write(p []byte) (int, error) {
+	c.start()
+	return c.Command.Write(p)
+}
+
+func (c *timeoutCmd) Read(p []byte) (int, error) {
+	c.start()
+	n, err := c.Command.Read(p)
+	if err != nil {
+		if err != io.EOF && context.Cause(c.ctx) == ErrTimeout {
+			err = fmt.Errorf("%w after %v: %w", ErrTimeout, c.timeout, err)
+		}
+		c.timer.Stop()
+		c.cancel(nil)
+	}
+	return n, err
+}
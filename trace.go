@@ -0,0 +1,295 @@
+package cmdio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Tracer receives events describing the execution of commands.
+// Trace may be called concurrently.
+type Tracer interface {
+	Trace(Event)
+}
+
+// TraceHook receives an [Event] when each command run by this package starts
+// and ends. By default, it is a [TextTracer] that writes to [Trace].
+var TraceHook Tracer = TextTracer{}
+
+// An EventKind identifies the kind of an [Event].
+type EventKind int
+
+const (
+	// Start events are emitted as commands start.
+	Start EventKind = iota
+	// End events are emitted once commands have finished.
+	End
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Start:
+		return "start"
+	case End:
+		return "end"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// An Event describes a command starting or ending.
+type Event struct {
+	Kind EventKind
+	// ID identifies the command. Its Start and End events share an ID.
+	ID   uint64
+	Time time.Time
+	// Cmd is the command, as formatted by its String method.
+	Cmd string
+	// Args are the arguments the command was instantiated with, if known.
+	Args []string
+	// Env is the environment of the Runner that ran the command, if known.
+	Env map[string]string
+	// Context is the context of the Runner that ran the command, if known.
+	Context context.Context
+	// Commander is the Commander of the Runner that ran the command, if known.
+	Commander Commander
+	// Attempt numbers the attempts to run a command retried according to
+	// [Runner.WithRetry], starting at 1. It is 0 for commands not retried.
+	Attempt int
+
+	// Pipeline lists every stage of the pipeline the command is part of,
+	// starting with its source. It is nil if the command is not part of a
+	// pipeline. The stages of a pipeline may come from different Runners,
+	// so the fields describing the Runner are not set, except for Context,
+	// which is taken from stages that implement [Contexter]. Other stages
+	// share the Context of the first stage that implements it.
+	Pipeline []string
+	// PipelineID identifies the pipeline. It is shared by every stage.
+	PipelineID uint64
+	// Stage is the index of the command within Pipeline.
+	Stage int
+
+	// The remaining fields are only set for End events.
+
+	Code     int           // Exit code, if the command implements [Coder].
+	Err      error         // The error the command failed with, if any.
+	Duration time.Duration // Time since the command started.
+	BytesIn  int64         // Bytes written to the command, if known.
+	BytesOut int64         // Bytes read from the command.
+}
+
+// A TextTracer writes a line to W for each command as it starts, in the
+// style of shell tracing. Pipelines are written as a single line when their
+// source starts, and retried commands are followed by their attempt number.
+//
+// If W is nil, lines are written to [Trace].
+type TextTracer struct {
+	W io.Writer
+}
+
+func (t TextTracer) Trace(e Event) {
+	if e.Kind != Start || e.Stage > 0 {
+		return
+	}
+	w := t.W
+	if w == nil {
+		w = Trace
+	}
+	switch {
+	case e.Pipeline != nil:
+		fmt.Fprintln(w, strings.Join(e.Pipeline, " | "))
+	case e.Attempt > 1:
+		fmt.Fprintf(w, "%s (attempt %d)\n", e.Cmd, e.Attempt)
+	default:
+		fmt.Fprintln(w, e.Cmd)
+	}
+}
+
+// A JSONTracer writes each event as a line of JSON.
+type JSONTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONTracer returns a [JSONTracer] that writes to w.
+func NewJSONTracer(w io.Writer) *JSONTracer {
+	return &JSONTracer{w: w}
+}
+
+type jsonEvent struct {
+	Kind       string            `json:"kind"`
+	ID         uint64            `json:"id"`
+	Time       time.Time         `json:"time"`
+	Cmd        string            `json:"cmd"`
+	Args       []string          `json:"args,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Attempt    int               `json:"attempt,omitempty"`
+	Pipeline   []string          `json:"pipeline,omitempty"`
+	PipelineID uint64            `json:"pipeline_id,omitempty"`
+	Stage      int               `json:"stage,omitempty"`
+	Code       int               `json:"code,omitempty"`
+	Err        string            `json:"error,omitempty"`
+	Duration   time.Duration     `json:"duration_ns,omitempty"`
+	BytesIn    int64             `json:"bytes_in,omitempty"`
+	BytesOut   int64             `json:"bytes_out,omitempty"`
+}
+
+func (t *JSONTracer) Trace(e Event) {
+	je := jsonEvent{
+		Kind:       e.Kind.String(),
+		ID:         e.ID,
+		Time:       e.Time,
+		Cmd:        e.Cmd,
+		Args:       e.Args,
+		Env:        e.Env,
+		Attempt:    e.Attempt,
+		Pipeline:   e.Pipeline,
+		PipelineID: e.PipelineID,
+		Stage:      e.Stage,
+		Code:       e.Code,
+		Duration:   e.Duration,
+		BytesIn:    e.BytesIn,
+		BytesOut:   e.BytesOut,
+	}
+	if e.Err != nil {
+		je.Err = e.Err.Error()
+	}
+	buf, err := json.Marshal(je)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.w.Write(append(buf, '\n'))
+}
+
+// MultiTracer returns a [Tracer] that sends each event to every tracer.
+func MultiTracer(tracers ...Tracer) Tracer {
+	return multiTracer(tracers)
+}
+
+type multiTracer []Tracer
+
+func (m multiTracer) Trace(e Event) {
+	for _, t := range m {
+		t.Trace(e)
+	}
+}
+
+var traceID atomic.Uint64
+
+// A span tracks a command between its Start and End events.
+type span struct {
+	ev      Event
+	secrets []string
+}
+
+// newSpan prepares to trace cmd, which was instantiated by rnr with args.
+// The runner may be nil if it is unknown.
+func newSpan(cmd any, rnr *Runner, args []string) *span {
+	s := &span{ev: Event{
+		Kind: Start,
+		ID:   traceID.Add(1),
+		Cmd:  strings.TrimRight(fmt.Sprintf("%v", cmd), "\n"),
+		Args: args,
+	}}
+	if rnr != nil {
+		s.secrets = rnr.opts.secrets
+		s.ev.Cmd = redact(s.secrets, s.ev.Cmd)
+		s.ev.Env = redactMap(s.secrets, rnr.env)
+		s.ev.Context = rnr.ctx
+		s.ev.Commander = rnr.Commander
+		if len(s.secrets) > 0 && args != nil {
+			s.ev.Args = make([]string, len(args))
+			for i, arg := range args {
+				s.ev.Args[i] = redact(s.secrets, arg)
+			}
+		}
+	}
+	return s
+}
+
+// start emits the Start event.
+func (s *span) start() *span {
+	s.ev.Time = time.Now()
+	TraceHook.Trace(s.ev)
+	return s
+}
+
+// end emits an End event for cmd.
+func (s *span) end(cmd any, err error, in, out int64) {
+	e := s.ev
+	e.Kind = End
+	e.Time = time.Now()
+	e.Duration = e.Time.Sub(s.ev.Time)
+	e.Err = err
+	if err != nil && len(s.secrets) > 0 {
+		e.Err = redactedError{err, s.secrets}
+	}
+	e.BytesIn, e.BytesOut = in, out
+	if c, ok := cmd.(Coder); ok {
+		e.Code = c.Code()
+	}
+	TraceHook.Trace(e)
+}
+
+// tracePipeline emits a Start event for each stage of a pipeline.
+func tracePipeline(src io.Reader, mid []io.ReadWriter) []*span {
+	var (
+		now     = time.Now()
+		spans   = make([]*span, len(mid)+1)
+		names   = make([]string, len(spans))
+		secrets = pipeSecrets(src, mid)
+	)
+	for i := range names {
+		names[i] = cmdString(stage(src, mid, i))
+	}
+	// Stages that do not know their context share that of the first stage
+	// that does, which is the context of the pipeline as a whole.
+	var ctx context.Context
+	ctxs := make([]context.Context, len(spans))
+	for i := range ctxs {
+		if c, ok := stage(src, mid, i).(Contexter); ok {
+			ctxs[i] = c.Context()
+			if ctx == nil {
+				ctx = ctxs[i]
+			}
+		}
+	}
+	id := traceID.Add(1)
+	for i := range spans {
+		if ctxs[i] == nil {
+			ctxs[i] = ctx
+		}
+		spans[i] = &span{ev: Event{
+			Kind:       Start,
+			ID:         traceID.Add(1),
+			Time:       now,
+			Cmd:        names[i],
+			Context:    ctxs[i],
+			Pipeline:   names,
+			PipelineID: id,
+			Stage:      i,
+		}, secrets: secrets}
+		TraceHook.Trace(spans[i].ev)
+	}
+	return spans
+}
+
+// traceEnd emits an End event for each stage of a pipeline.
+func traceEnd(
+	spans []*span, src io.Reader, mid []io.ReadWriter,
+	errs []error, counts []atomic.Int64,
+) {
+	for i, s := range spans {
+		var in int64
+		if i > 0 {
+			in = counts[i-1].Load()
+		}
+		s.end(stage(src, mid, i), errs[i], in, counts[i].Load())
+	}
+}
@@ -0,0 +1,136 @@
+package cmdio_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"lesiw.io/cmdio"
+	"lesiw.io/cmdio/sys"
+)
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []cmdio.Event
+}
+
+func (l *eventLog) Trace(e cmdio.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func TestTraceEvents(t *testing.T) {
+	var log eventLog
+	swap[cmdio.Tracer](t, &cmdio.TraceHook, &log)
+	rnr := sys.Runner().WithEnv(map[string]string{"GREETING": "hi"})
+
+	_, _ = rnr.Get("sh", "-c", `printf "$GREETING"; exit 3`)
+
+	if got, want := len(log.events), 2; got != want {
+		t.Fatalf("got %d events, want %d", got, want)
+	}
+	start, end := log.events[0], log.events[1]
+	if got, want := start.Kind, cmdio.Start; got != want {
+		t.Errorf("events[0].Kind = %v, want %v", got, want)
+	}
+	if got, want := end.Kind, cmdio.End; got != want {
+		t.Errorf("events[1].Kind = %v, want %v", got, want)
+	}
+	if start.ID != end.ID {
+		t.Errorf("event IDs = %d, %d, want equal", start.ID, end.ID)
+	}
+	if got, want := start.Env["GREETING"], "hi"; got != want {
+		t.Errorf("events[0].Env[GREETING] = %q, want %q", got, want)
+	}
+	if got, want := end.Code, 3; got != want {
+		t.Errorf("events[1].Code = %d, want %d", got, want)
+	}
+	if end.Err == nil {
+		t.Errorf("events[1].Err = <nil>, want error")
+	}
+	if got, want := end.BytesOut, int64(2); got != want {
+		t.Errorf("events[1].BytesOut = %d, want %d", got, want)
+	}
+}
+
+func TestTracePipeline(t *testing.T) {
+	var log eventLog
+	swap[cmdio.Tracer](t, &cmdio.TraceHook, &log)
+	rnr := sys.Runner()
+
+	_, err := cmdio.GetPipe(
+		rnr.Command("echo", "hello"),
+		rnr.Command("tr", "a-z", "A-Z"),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(log.events), 4; got != want {
+		t.Fatalf("got %d events, want %d", got, want)
+	}
+	pipeline := []string{"echo hello", "tr a-z A-Z"}
+	for i, e := range log.events {
+		if got, want := e.Pipeline, pipeline; !cmp.Equal(got, want) {
+			t.Errorf("events[%d].Pipeline = %q, want %q", i, got, want)
+		}
+		if got, want := e.Stage, i%2; got != want {
+			t.Errorf("events[%d].Stage = %d, want %d", i, got, want)
+		}
+	}
+	tr := log.events[3]
+	if got, want := tr.BytesIn, int64(6); got != want {
+		t.Errorf("tr event BytesIn = %d, want %d", got, want)
+	}
+	if got, want := tr.BytesOut, int64(6); got != want {
+		t.Errorf("tr event BytesOut = %d, want %d", got, want)
+	}
+}
+
+func TestJSONTracer(t *testing.T) {
+	var buf, text bytes.Buffer
+	swap[cmdio.Tracer](t, &cmdio.TraceHook, cmdio.MultiTracer(
+		cmdio.TextTracer{W: &text},
+		cmdio.NewJSONTracer(&buf),
+	))
+	swap(t, &cmdio.Trace, io.Discard)
+	rnr := sys.Runner()
+
+	_, _ = rnr.Get("sh", "-c", "echo hello; exit 1")
+
+	if got, want := text.String(), "sh -c 'echo hello; exit 1'\n"; got != want {
+		t.Errorf("text trace = %q, want %q", got, want)
+	}
+	var events []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("json.Unmarshal(%q) err = %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("got %d JSON events, want %d", got, want)
+	}
+	if got, want := events[0]["kind"], "start"; got != want {
+		t.Errorf("events[0].kind = %v, want %v", got, want)
+	}
+	if got, want := events[1]["kind"], "end"; got != want {
+		t.Errorf("events[1].kind = %v, want %v", got, want)
+	}
+	if got, want := events[1]["code"], 1.0; got != want {
+		t.Errorf("events[1].code = %v, want %v", got, want)
+	}
+	if got, want := events[1]["error"], "exit status 1"; got != want {
+		t.Errorf("events[1].error = %v, want %v", got, want)
+	}
+	if got, want := events[1]["bytes_out"], 6.0; got != want {
+		t.Errorf("events[1].bytes_out = %v, want %v", got, want)
+	}
+}